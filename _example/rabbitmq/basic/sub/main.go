@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/ramoozorg/event-driven/rabbitmq"
@@ -14,12 +15,12 @@ type person struct {
 
 func main() {
 	done := make(chan bool, 1)
-	conn, err := rabbitmq.NewConnection("test", &rabbitmq.Options{
+	conn, err := rabbitmq.NewConnectionContext(context.Background(), "test", &rabbitmq.Options{
 		UriAddress:      rabbitmq.CreateURIAddress("guest", "guest", "localhost:5672", ""),
 		DurableExchange: true,
 		AutoAck:         true,
 		ExclusiveQueue:  false,
-	}, nil)
+	})
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,113 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// AckEventHandler handle event from specific queue, the event is acknowledged when it returns nil
+// and the FailurePolicy of the queue is applied when it returns error or panics
+type AckEventHandler func(ctx context.Context, queue string, delivery Delivery) error
+
+// FailurePolicy is the action applied to an event whose handler failed
+type FailurePolicy int
+
+const (
+	REQUEUE_ON_FAILURE     FailurePolicy = iota // REQUEUE_ON_FAILURE negatively acknowledge the event and requeue it immediately
+	REJECT_ON_FAILURE                           // REJECT_ON_FAILURE reject the event without requeue, it is dead-lettered if the queue has dead letter exchange
+	RETRY_LATER_ON_FAILURE                      // RETRY_LATER_ON_FAILURE requeue the event after ConsumerOptions.RetryDelay
+)
+
+// DeclareAckConsumerQueue declare new queue and bind queue and bind exchange with routing key, events of the queue
+// are consumed with manual acknowledgement and acknowledged by the library based on the result of handler
+func (c *Connection) DeclareAckConsumerQueue(handler AckEventHandler, opts *ConsumerOptions, queue, exchange string, routingKey ...string) error {
+	if handler == nil {
+		return HANDLER_INVALID_ERROR
+	}
+	consumerOpts := validateConsumerOptions(opts)
+	ackHandler := c.ackEventHandler(handler, consumerOpts)
+	eventHandler := func(queue string, delivery Delivery) {
+		_ = ackHandler(c.ctx, queue, delivery)
+	}
+	return c.consumerQueueDeclare(eventHandler, &consumerQueue{opts: consumerOpts, manualAck: true, handler: ackHandler}, queue, exchange, routingKey...)
+}
+
+// ackEventHandler wraps handler into a handler which acknowledge the event on success and apply failure policy on error,
+// the error of handler is returned after applying failure policy
+func (c *Connection) ackEventHandler(handler AckEventHandler, opts *ConsumerOptions) AckEventHandler {
+	return func(ctx context.Context, queue string, delivery Delivery) error {
+		if err := handler(ctx, queue, delivery); err != nil {
+			c.handleFailure(opts, queue, delivery, err)
+			return err
+		}
+		if err := c.settle(queue, ACKED_DELIVERY, delivery.Ack(false)); err != nil {
+			c.handleError(queue, delivery, err)
+		}
+		return nil
+	}
+}
+
+// handleFailure report err of the event and apply the failure policy, events which cannot be decoded are always rejected
+func (c *Connection) handleFailure(opts *ConsumerOptions, queue string, delivery Delivery, err error) {
+	c.handleError(queue, delivery, err)
+	var decodeErr *DecodeError
+	isDecodeErr := errors.As(err, &decodeErr)
+	if opts.Retry != nil {
+		if retryErr := c.retryEvent(opts.Retry, queue, delivery, isDecodeErr); retryErr != nil {
+			c.handleError(queue, delivery, retryErr)
+			// requeue the event after RetryDelay to retry it when it cannot be published to retry queue,
+			// requeuing immediately redelivers it in a loop while rabbitMQ is blocked or unreachable
+			c.requeueLater(opts, queue, delivery)
+		}
+		return
+	}
+	policy := opts.FailurePolicy
+	if isDecodeErr {
+		policy = REJECT_ON_FAILURE
+	}
+	var ackErr error
+	switch policy {
+	case REJECT_ON_FAILURE:
+		ackErr = c.settle(queue, REJECTED_DELIVERY, delivery.Reject(false))
+	case RETRY_LATER_ON_FAILURE:
+		c.requeueLater(opts, queue, delivery)
+	default:
+		ackErr = c.settle(queue, NACKED_DELIVERY, delivery.Nack(false, true))
+	}
+	if ackErr != nil {
+		c.handleError(queue, delivery, ackErr)
+	}
+}
+
+// requeueLater negatively acknowledge the event with requeue after ConsumerOptions.RetryDelay,
+// Shutdown requeues the waiting events immediately
+func (c *Connection) requeueLater(opts *ConsumerOptions, queue string, delivery Delivery) {
+	c.mu.RLock()
+	cs := c.consumers[queue]
+	c.mu.RUnlock()
+	r := &delayedRequeue{delivery: delivery}
+	requeue := func() {
+		if cs != nil && !cs.removeRequeue(r) {
+			// requeued by Shutdown
+			return
+		}
+		c.requeue(queue, delivery)
+	}
+	if cs == nil {
+		time.AfterFunc(opts.RetryDelay, requeue)
+		return
+	}
+	// the timer is registered before it can fire, removeRequeue waits for cs.mu
+	cs.mu.Lock()
+	r.timer = time.AfterFunc(opts.RetryDelay, requeue)
+	cs.requeues[r] = struct{}{}
+	cs.mu.Unlock()
+}
+
+// requeue negatively acknowledge the event with requeue
+func (c *Connection) requeue(queue string, delivery Delivery) {
+	if err := c.settle(queue, NACKED_DELIVERY, delivery.Nack(false, true)); err != nil {
+		c.handleError(queue, delivery, err)
+	}
+}
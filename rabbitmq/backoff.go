@@ -0,0 +1,110 @@
+package rabbitmq
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// BackoffPolicy return delay before reconnecting to rabbitMQ, attempt is the number of failed connecting starting from 1
+type BackoffPolicy interface {
+	Next(attempt int) time.Duration
+}
+
+// ReconnectHandler is called on every failed reconnecting with delay before the next attempt,
+// delay is zero on the last attempt which reached ReconnectMaxAttempts or ReconnectMaxElapsed
+type ReconnectHandler func(attempt int, delay time.Duration, err error)
+
+// ConstantBackoff wait Delay before every reconnecting
+type ConstantBackoff struct {
+	Delay time.Duration
+}
+
+// Next return constant delay
+func (b ConstantBackoff) Next(int) time.Duration {
+	return b.Delay
+}
+
+// ExponentialBackoff multiply delay by Multiplier on every attempt up to MaxDelay, Jitter between 0 and 1 is the
+// random fraction subtracted from the delay, so clients do not reconnect to rabbitMQ at the same time
+type ExponentialBackoff struct {
+	InitialDelay time.Duration // InitialDelay before the first reconnecting, default is 1 second
+	Multiplier   float64       // Multiplier of delay, default is 2
+	MaxDelay     time.Duration // MaxDelay between reconnecting, default is 1 minute
+	Jitter       float64       // Jitter random fraction of delay subtracted from it
+}
+
+// Next return exponential delay of attempt with jitter
+func (b ExponentialBackoff) Next(attempt int) time.Duration {
+	initialDelay, multiplier, maxDelay := b.InitialDelay, b.Multiplier, b.MaxDelay
+	if initialDelay <= 0 {
+		initialDelay = 1 * time.Second
+	}
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	if maxDelay <= 0 {
+		maxDelay = 1 * time.Minute
+	}
+	delay := float64(initialDelay)
+	for i := 1; i < attempt && delay < float64(maxDelay); i++ {
+		delay *= multiplier
+	}
+	if delay > float64(maxDelay) {
+		delay = float64(maxDelay)
+	}
+	if b.Jitter > 0 {
+		delay -= delay * b.Jitter * jitter.float64()
+	}
+	return time.Duration(delay)
+}
+
+// FibonacciBackoff wait Unit multiplied by fibonacci number of attempt up to MaxDelay
+type FibonacciBackoff struct {
+	Unit     time.Duration // Unit of delay, default is 1 second
+	MaxDelay time.Duration // MaxDelay between reconnecting, default is 1 minute
+}
+
+// Next return fibonacci delay of attempt
+func (b FibonacciBackoff) Next(attempt int) time.Duration {
+	unit, maxDelay := b.Unit, b.MaxDelay
+	if unit <= 0 {
+		unit = 1 * time.Second
+	}
+	if maxDelay <= 0 {
+		maxDelay = 1 * time.Minute
+	}
+	delay, next := unit, 2*unit
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay, next = next, delay+next
+	}
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// linearBackoff add one second to delayReconnectTime on every attempt up to ten seconds
+type linearBackoff struct{}
+
+// Next return linear delay of attempt
+func (linearBackoff) Next(attempt int) time.Duration {
+	if attempt > 11 {
+		attempt = 11
+	}
+	return delayReconnectTime + time.Duration(attempt-1)*time.Second
+}
+
+// lockedRand is random source safe for concurrent use
+type lockedRand struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+var jitter = &lockedRand{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+func (r *lockedRand) float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.Float64()
+}
@@ -0,0 +1,66 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoffNext(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff ExponentialBackoff
+		attempt int
+		want    time.Duration
+	}{
+		{"default first attempt", ExponentialBackoff{}, 1, 1 * time.Second},
+		{"default second attempt", ExponentialBackoff{}, 2, 2 * time.Second},
+		{"default fourth attempt", ExponentialBackoff{}, 4, 8 * time.Second},
+		{"default max delay", ExponentialBackoff{}, 10, 1 * time.Minute},
+		{"invalid multiplier", ExponentialBackoff{InitialDelay: time.Second, Multiplier: 0.5}, 3, 4 * time.Second},
+		{"custom multiplier", ExponentialBackoff{InitialDelay: 100 * time.Millisecond, Multiplier: 3}, 3, 900 * time.Millisecond},
+		{"custom max delay", ExponentialBackoff{InitialDelay: time.Second, MaxDelay: 5 * time.Second}, 4, 5 * time.Second},
+		{"initial delay above max delay", ExponentialBackoff{InitialDelay: time.Minute, MaxDelay: time.Second}, 1, time.Second},
+		{"large attempt", ExponentialBackoff{}, 1 << 30, 1 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.backoff.Next(tt.attempt); got != tt.want {
+				t.Fatalf("Next(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExponentialBackoffJitter(t *testing.T) {
+	b := ExponentialBackoff{InitialDelay: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if got := b.Next(2); got > 2*time.Second || got < 1*time.Second {
+			t.Fatalf("Next(2) with jitter 0.5 = %v, want between 1s and 2s", got)
+		}
+	}
+}
+
+func TestFibonacciBackoffNext(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff FibonacciBackoff
+		attempt int
+		want    time.Duration
+	}{
+		{"default first attempt", FibonacciBackoff{}, 1, 1 * time.Second},
+		{"default second attempt", FibonacciBackoff{}, 2, 2 * time.Second},
+		{"default third attempt", FibonacciBackoff{}, 3, 3 * time.Second},
+		{"default fifth attempt", FibonacciBackoff{}, 5, 8 * time.Second},
+		{"default max delay", FibonacciBackoff{}, 20, 1 * time.Minute},
+		{"custom unit", FibonacciBackoff{Unit: 100 * time.Millisecond}, 4, 500 * time.Millisecond},
+		{"custom max delay", FibonacciBackoff{MaxDelay: 4 * time.Second}, 5, 4 * time.Second},
+		{"large attempt", FibonacciBackoff{}, 1 << 30, 1 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.backoff.Next(tt.attempt); got != tt.want {
+				t.Fatalf("Next(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
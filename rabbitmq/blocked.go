@@ -0,0 +1,45 @@
+package rabbitmq
+
+import (
+	"context"
+
+	"github.com/streadway/amqp"
+)
+
+// IsBlocked check rabbitMQ blocked the publishing connection, e.g. on memory or disk alarms
+func (c *Connection) IsBlocked() bool {
+	blocked, _ := c.publisherSession.blockedState()
+	return blocked
+}
+
+// waitUnblocked wait until publishing connection is unblocked or ctx done
+func (c *Connection) waitUnblocked(ctx context.Context) error {
+	for {
+		blocked, changed := c.publisherSession.blockedState()
+		if !blocked {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return BROKER_BLOCKED_ERROR
+		case <-changed:
+		}
+	}
+}
+
+// listenBlocked update blocked state of session until its connection closed
+func (c *Connection) listenBlocked(s *session, conn *amqp.Connection, blockings chan amqp.Blocking) {
+	for b := range blockings {
+		if !s.setBlocked(conn, b.Active) {
+			continue
+		}
+		if b.Active {
+			c.logger.Warn("rabbitMQ blocked connection", "session", s.name, "reason", b.Reason)
+		} else {
+			c.logger.Info("rabbitMQ unblocked connection", "session", s.name)
+		}
+		if c.ConnOpt.BlockedHandler != nil {
+			c.ConnOpt.BlockedHandler(s == c.publisherSession, b.Active, b.Reason)
+		}
+	}
+}
@@ -0,0 +1,824 @@
+package rabbitmq
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeBroker is an in-memory AMQP 0-9-1 broker implementing the subset of the protocol used by Connection:
+// exchanges, queues, bindings, publishing with confirms and returns, consuming with acknowledgements and
+// basic.get. Tests use it to drop connections and close channels like rabbitMQ does on errors.
+type fakeBroker struct {
+	t        *testing.T
+	listener net.Listener
+
+	mu        sync.Mutex
+	conns     map[*fakeConn]struct{}
+	exchanges map[string]string // exchanges kind by name
+	queues    map[string]*fakeQueue
+	bindings  []fakeBinding
+	accepted  int // accepted number of accepted connections
+
+	confirmBatch int // confirmBatch acknowledge publishings by one multiple ack for every confirmBatch publishings, each one is acknowledged if zero
+}
+
+type fakeBinding struct {
+	queue, exchange, key string
+}
+
+type fakeMessage struct {
+	exchange, routingKey string
+	properties           []byte // properties flags and list of the content header
+	body                 []byte
+	redelivered          bool
+}
+
+type fakeQueue struct {
+	name      string
+	messages  []*fakeMessage
+	consumers []*fakeConsumer
+	next      int // next consumer in round-robin order
+}
+
+type fakeConsumer struct {
+	tag     string
+	channel *fakeChannel
+	noAck   bool
+}
+
+type fakeUnacked struct {
+	queue   string
+	message *fakeMessage
+}
+
+type fakeChannel struct {
+	id          uint16
+	conn        *fakeConn
+	confirm     bool
+	publishTag  uint64
+	deliveryTag uint64
+	unacked     map[uint64]fakeUnacked
+	closing     bool // closing channel is closed by broker and waits for close-ok
+
+	// content of publishing which is being received
+	publish     *fakeMessage
+	mandatory   bool
+	contentSize uint64
+}
+
+type fakeConn struct {
+	broker   *fakeBroker
+	netConn  net.Conn
+	channels map[uint16]*fakeChannel
+
+	outMu  sync.Mutex
+	out    [][]byte
+	outCh  chan struct{}
+	closed bool
+}
+
+// newFakeBroker start fake broker listening on a local port, tlsConfig enables TLS if it is not nil
+func newFakeBroker(t *testing.T, tlsConfig *tls.Config) *fakeBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	b := &fakeBroker{
+		t:         t,
+		listener:  listener,
+		conns:     make(map[*fakeConn]struct{}),
+		exchanges: map[string]string{"": "direct"},
+		queues:    make(map[string]*fakeQueue),
+	}
+	go b.accept()
+	t.Cleanup(b.close)
+	return b
+}
+
+// uri return address of the broker with scheme
+func (b *fakeBroker) uri(scheme string) string {
+	return fmt.Sprintf("%s://guest:guest@%s/", scheme, b.listener.Addr().String())
+}
+
+func (b *fakeBroker) accept() {
+	for {
+		netConn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &fakeConn{
+			broker:   b,
+			netConn:  netConn,
+			channels: make(map[uint16]*fakeChannel),
+			outCh:    make(chan struct{}, 1),
+		}
+		b.mu.Lock()
+		b.conns[c] = struct{}{}
+		b.accepted++
+		b.mu.Unlock()
+		go c.write()
+		go c.serve()
+	}
+}
+
+// close stop listening and drop all connections
+func (b *fakeBroker) close() {
+	_ = b.listener.Close()
+	b.dropConnections()
+}
+
+// dropConnections close network connections of all clients, like a network failure or broker restart
+func (b *fakeBroker) dropConnections() {
+	b.mu.Lock()
+	conns := make([]*fakeConn, 0, len(b.conns))
+	for c := range b.conns {
+		conns = append(conns, c)
+	}
+	b.mu.Unlock()
+	for _, c := range conns {
+		_ = c.netConn.Close()
+	}
+}
+
+// connections return number of accepted connections
+func (b *fakeBroker) connections() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.accepted
+}
+
+// openConnections return number of connections which are not closed
+func (b *fakeBroker) openConnections() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.conns)
+}
+
+// deleteExchange delete exchange and its bindings like deleting it on the management UI
+func (b *fakeBroker) deleteExchange(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.exchanges, name)
+	bindings := b.bindings[:0]
+	for _, binding := range b.bindings {
+		if binding.exchange != name {
+			bindings = append(bindings, binding)
+		}
+	}
+	b.bindings = bindings
+}
+
+// deleteQueue delete queue and its bindings and cancel its consumers like deleting it on the management UI
+func (b *fakeBroker) deleteQueue(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q, ok := b.queues[name]
+	if !ok {
+		return
+	}
+	delete(b.queues, name)
+	for _, cs := range q.consumers {
+		w := &argWriter{}
+		w.shortstr(cs.tag)
+		w.bits(false)
+		cs.channel.conn.sendMethod(cs.channel.id, 60, 30, w.Bytes())
+	}
+	bindings := b.bindings[:0]
+	for _, binding := range b.bindings {
+		if binding.queue != name {
+			bindings = append(bindings, binding)
+		}
+	}
+	b.bindings = bindings
+}
+
+// messageCount return number of ready messages of queue
+func (b *fakeBroker) messageCount(queue string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if q, ok := b.queues[queue]; ok {
+		return len(q.messages)
+	}
+	return 0
+}
+
+// enqueue put message directly into queue
+func (b *fakeBroker) enqueue(queue string, m *fakeMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := b.queue(queue)
+	q.messages = append(q.messages, m)
+	b.dispatch(q)
+}
+
+// queue return queue by name and create it if it does not exist, b.mu must be held
+func (b *fakeBroker) queue(name string) *fakeQueue {
+	q, ok := b.queues[name]
+	if !ok {
+		q = &fakeQueue{name: name}
+		b.queues[name] = q
+	}
+	return q
+}
+
+// route return queues which message published to exchange with routingKey is routed to, b.mu must be held
+func (b *fakeBroker) route(exchange, routingKey string) []*fakeQueue {
+	if exchange == "" {
+		if q, ok := b.queues[routingKey]; ok {
+			return []*fakeQueue{q}
+		}
+		return nil
+	}
+	kind := b.exchanges[exchange]
+	var queues []*fakeQueue
+	seen := make(map[string]bool)
+	for _, binding := range b.bindings {
+		if binding.exchange != exchange || seen[binding.queue] {
+			continue
+		}
+		matched := false
+		switch kind {
+		case "fanout", "headers":
+			matched = true
+		case "topic":
+			matched = topicMatch(strings.Split(binding.key, "."), strings.Split(routingKey, "."))
+		default:
+			matched = binding.key == routingKey
+		}
+		if q, ok := b.queues[binding.queue]; ok && matched {
+			seen[binding.queue] = true
+			queues = append(queues, q)
+		}
+	}
+	return queues
+}
+
+// topicMatch match words of routing key with words of binding pattern
+func topicMatch(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if topicMatch(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && topicMatch(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && topicMatch(pattern[1:], words[1:])
+	}
+}
+
+// dispatch deliver ready messages of queue to its consumers in round-robin order, b.mu must be held
+func (b *fakeBroker) dispatch(q *fakeQueue) {
+	for len(q.messages) > 0 && len(q.consumers) > 0 {
+		m := q.messages[0]
+		q.messages = q.messages[1:]
+		cs := q.consumers[q.next%len(q.consumers)]
+		q.next++
+		ch := cs.channel
+		ch.deliveryTag++
+		if !cs.noAck {
+			ch.unacked[ch.deliveryTag] = fakeUnacked{queue: q.name, message: m}
+		}
+		w := &argWriter{}
+		w.shortstr(cs.tag)
+		w.longlong(ch.deliveryTag)
+		w.bits(m.redelivered)
+		w.shortstr(m.exchange)
+		w.shortstr(m.routingKey)
+		ch.conn.sendContent(ch.id, 60, 60, w.Bytes(), m)
+	}
+}
+
+// requeue put unacknowledged messages of channel back to their queues, b.mu must be held
+func (b *fakeBroker) requeue(ch *fakeChannel) {
+	touched := make(map[*fakeQueue]bool)
+	for tag, u := range ch.unacked {
+		delete(ch.unacked, tag)
+		q := b.queue(u.queue)
+		u.message.redelivered = true
+		q.messages = append([]*fakeMessage{u.message}, q.messages...)
+		touched[q] = true
+	}
+	for _, q := range b.queues {
+		consumers := q.consumers[:0]
+		for _, cs := range q.consumers {
+			if cs.channel != ch {
+				consumers = append(consumers, cs)
+			}
+		}
+		q.consumers = consumers
+	}
+	for q := range touched {
+		b.dispatch(q)
+	}
+}
+
+// serve read frames of connection until it is closed
+func (c *fakeConn) serve() {
+	defer c.cleanup()
+	r := bufio.NewReader(c.netConn)
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header, []byte("AMQP\x00\x00\x09\x01")) {
+		return
+	}
+	w := &argWriter{}
+	w.octet(0)
+	w.octet(9)
+	w.table()
+	w.longstr("PLAIN")
+	w.longstr("en_US")
+	c.sendMethod(0, 10, 10, w.Bytes())
+	for {
+		typ, channel, payload, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		if !c.handle(typ, channel, payload) {
+			return
+		}
+	}
+}
+
+// cleanup requeue unacknowledged messages and forget the connection
+func (c *fakeConn) cleanup() {
+	b := c.broker
+	b.mu.Lock()
+	for _, ch := range c.channels {
+		b.requeue(ch)
+	}
+	delete(b.conns, c)
+	b.mu.Unlock()
+	c.outMu.Lock()
+	c.closed = true
+	c.outMu.Unlock()
+	select {
+	case c.outCh <- struct{}{}:
+	default:
+	}
+}
+
+// handle process a frame and reports whether connection is still open
+func (c *fakeConn) handle(typ byte, channel uint16, payload []byte) bool {
+	b := c.broker
+	switch typ {
+	case 8: // heartbeat
+		c.send(frameBytes(8, 0, nil))
+		return true
+	case 2: // content header
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		ch := c.channels[channel]
+		if ch == nil || ch.publish == nil {
+			return true
+		}
+		ch.contentSize = binary.BigEndian.Uint64(payload[4:12])
+		ch.publish.properties = append([]byte(nil), payload[12:]...)
+		if ch.contentSize == 0 {
+			b.published(ch)
+		}
+		return true
+	case 3: // content body
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		ch := c.channels[channel]
+		if ch == nil || ch.publish == nil {
+			return true
+		}
+		ch.publish.body = append(ch.publish.body, payload...)
+		if uint64(len(ch.publish.body)) >= ch.contentSize {
+			b.published(ch)
+		}
+		return true
+	}
+	r := &argReader{b: payload}
+	class, method := r.short(), r.short()
+	if class == 10 {
+		return c.handleConnection(method, r)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := c.channels[channel]
+	if class == 20 && method == 10 { // channel.open
+		c.channels[channel] = &fakeChannel{id: channel, conn: c, unacked: make(map[uint64]fakeUnacked)}
+		w := &argWriter{}
+		w.longstr("")
+		c.sendMethod(channel, 20, 11, w.Bytes())
+		return true
+	}
+	if ch == nil {
+		return true
+	}
+	if ch.closing {
+		if class == 20 && method == 41 { // channel.close-ok
+			delete(c.channels, channel)
+		}
+		return true
+	}
+	switch {
+	case class == 20 && method == 40: // channel.close
+		b.requeue(ch)
+		delete(c.channels, channel)
+		c.sendMethod(channel, 20, 41, nil)
+	case class == 40 && method == 10: // exchange.declare
+		r.short()
+		name, kind := r.shortstr(), r.shortstr()
+		passive, _, _, _, noWait := r.bit(), r.bit(), r.bit(), r.bit(), r.bit()
+		if _, ok := b.exchanges[name]; passive && !ok {
+			b.closeChannel(ch, 404, "NOT_FOUND - no exchange '"+name+"'", class, method)
+			return true
+		}
+		b.exchanges[name] = kind
+		if !noWait {
+			c.sendMethod(channel, 40, 11, nil)
+		}
+	case class == 50 && method == 10: // queue.declare
+		r.short()
+		name := r.shortstr()
+		passive, _, _, _, noWait := r.bit(), r.bit(), r.bit(), r.bit(), r.bit()
+		if _, ok := b.queues[name]; passive && !ok {
+			b.closeChannel(ch, 404, "NOT_FOUND - no queue '"+name+"'", class, method)
+			return true
+		}
+		q := b.queue(name)
+		if !noWait {
+			w := &argWriter{}
+			w.shortstr(name)
+			w.long(uint32(len(q.messages)))
+			w.long(uint32(len(q.consumers)))
+			c.sendMethod(channel, 50, 11, w.Bytes())
+		}
+	case class == 50 && method == 20: // queue.bind
+		r.short()
+		queue, exchange, key := r.shortstr(), r.shortstr(), r.shortstr()
+		noWait := r.bit()
+		if _, ok := b.exchanges[exchange]; !ok {
+			b.closeChannel(ch, 404, "NOT_FOUND - no exchange '"+exchange+"'", class, method)
+			return true
+		}
+		b.bindings = append(b.bindings, fakeBinding{queue: queue, exchange: exchange, key: key})
+		if !noWait {
+			c.sendMethod(channel, 50, 21, nil)
+		}
+	case class == 60 && method == 10: // basic.qos
+		c.sendMethod(channel, 60, 11, nil)
+	case class == 60 && method == 20: // basic.consume
+		r.short()
+		queue, tag := r.shortstr(), r.shortstr()
+		_, noAck, _, noWait := r.bit(), r.bit(), r.bit(), r.bit()
+		q, ok := b.queues[queue]
+		if !ok {
+			b.closeChannel(ch, 404, "NOT_FOUND - no queue '"+queue+"'", class, method)
+			return true
+		}
+		if !noWait {
+			w := &argWriter{}
+			w.shortstr(tag)
+			c.sendMethod(channel, 60, 21, w.Bytes())
+		}
+		q.consumers = append(q.consumers, &fakeConsumer{tag: tag, channel: ch, noAck: noAck})
+		b.dispatch(q)
+	case class == 60 && method == 30: // basic.cancel
+		tag := r.shortstr()
+		noWait := r.bit()
+		for _, q := range b.queues {
+			consumers := q.consumers[:0]
+			for _, cs := range q.consumers {
+				if cs.channel != ch || cs.tag != tag {
+					consumers = append(consumers, cs)
+				}
+			}
+			q.consumers = consumers
+		}
+		if !noWait {
+			w := &argWriter{}
+			w.shortstr(tag)
+			c.sendMethod(channel, 60, 31, w.Bytes())
+		}
+	case class == 60 && method == 40: // basic.publish
+		r.short()
+		exchange, routingKey := r.shortstr(), r.shortstr()
+		ch.mandatory = r.bit()
+		ch.publish = &fakeMessage{exchange: exchange, routingKey: routingKey}
+	case class == 60 && method == 70: // basic.get
+		r.short()
+		queue := r.shortstr()
+		noAck := r.bit()
+		q, ok := b.queues[queue]
+		if !ok {
+			b.closeChannel(ch, 404, "NOT_FOUND - no queue '"+queue+"'", class, method)
+			return true
+		}
+		if len(q.messages) == 0 {
+			w := &argWriter{}
+			w.shortstr("")
+			c.sendMethod(channel, 60, 72, w.Bytes())
+			return true
+		}
+		m := q.messages[0]
+		q.messages = q.messages[1:]
+		ch.deliveryTag++
+		if !noAck {
+			ch.unacked[ch.deliveryTag] = fakeUnacked{queue: queue, message: m}
+		}
+		w := &argWriter{}
+		w.longlong(ch.deliveryTag)
+		w.bits(m.redelivered)
+		w.shortstr(m.exchange)
+		w.shortstr(m.routingKey)
+		w.long(uint32(len(q.messages)))
+		c.sendContent(channel, 60, 71, w.Bytes(), m)
+	case class == 60 && method == 80: // basic.ack
+		tag := r.longlong()
+		multiple := r.bit()
+		b.settle(ch, tag, multiple, false)
+	case class == 60 && method == 90: // basic.reject
+		tag := r.longlong()
+		requeue := r.bit()
+		b.settle(ch, tag, false, requeue)
+	case class == 60 && method == 120: // basic.nack
+		tag := r.longlong()
+		multiple, requeue := r.bit(), r.bit()
+		b.settle(ch, tag, multiple, requeue)
+	case class == 85 && method == 10: // confirm.select
+		ch.confirm = true
+		if noWait := r.bit(); !noWait {
+			c.sendMethod(channel, 85, 11, nil)
+		}
+	default:
+		b.t.Errorf("fake broker does not support method %d.%d", class, method)
+	}
+	return true
+}
+
+// handleConnection process methods of connection class and reports whether connection is still open
+func (c *fakeConn) handleConnection(method uint16, r *argReader) bool {
+	switch method {
+	case 11: // connection.start-ok
+		w := &argWriter{}
+		w.short(2047)
+		w.long(131072)
+		w.short(0)
+		c.sendMethod(0, 10, 30, w.Bytes())
+	case 40: // connection.open
+		w := &argWriter{}
+		w.shortstr("")
+		c.sendMethod(0, 10, 41, w.Bytes())
+	case 50: // connection.close
+		c.sendMethod(0, 10, 51, nil)
+		return false
+	case 51: // connection.close-ok
+		return false
+	}
+	return true
+}
+
+// published route the received publishing, b.mu must be held
+func (b *fakeBroker) published(ch *fakeChannel) {
+	m := ch.publish
+	ch.publish = nil
+	if _, ok := b.exchanges[m.exchange]; !ok {
+		b.closeChannel(ch, 404, "NOT_FOUND - no exchange '"+m.exchange+"'", 60, 40)
+		return
+	}
+	queues := b.route(m.exchange, m.routingKey)
+	if len(queues) == 0 && ch.mandatory {
+		w := &argWriter{}
+		w.short(312)
+		w.shortstr("NO_ROUTE")
+		w.shortstr(m.exchange)
+		w.shortstr(m.routingKey)
+		ch.conn.sendContent(ch.id, 60, 50, w.Bytes(), m)
+	}
+	for _, q := range queues {
+		q.messages = append(q.messages, &fakeMessage{exchange: m.exchange, routingKey: m.routingKey, properties: m.properties, body: m.body})
+		b.dispatch(q)
+	}
+	if ch.confirm {
+		ch.publishTag++
+		if b.confirmBatch > 0 && ch.publishTag%uint64(b.confirmBatch) != 0 {
+			return
+		}
+		w := &argWriter{}
+		w.longlong(ch.publishTag)
+		w.bits(b.confirmBatch > 0)
+		ch.conn.sendMethod(ch.id, 60, 80, w.Bytes())
+	}
+}
+
+// settle acknowledge or negatively acknowledge deliveries of channel, b.mu must be held
+func (b *fakeBroker) settle(ch *fakeChannel, tag uint64, multiple, requeue bool) {
+	for t, u := range ch.unacked {
+		if t != tag && !(multiple && t < tag) {
+			continue
+		}
+		delete(ch.unacked, t)
+		if requeue {
+			q := b.queue(u.queue)
+			u.message.redelivered = true
+			q.messages = append([]*fakeMessage{u.message}, q.messages...)
+			b.dispatch(q)
+		}
+	}
+}
+
+// closeChannel close channel with error like rabbitMQ channel exceptions, b.mu must be held
+func (b *fakeBroker) closeChannel(ch *fakeChannel, code uint16, text string, class, method uint16) {
+	b.requeue(ch)
+	ch.closing = true
+	w := &argWriter{}
+	w.short(code)
+	w.shortstr(text)
+	w.short(class)
+	w.short(method)
+	ch.conn.sendMethod(ch.id, 20, 40, w.Bytes())
+}
+
+func (c *fakeConn) sendMethod(channel uint16, class, method uint16, args []byte) {
+	c.send(methodFrame(channel, class, method, args))
+}
+
+// sendContent send method followed by content of message
+func (c *fakeConn) sendContent(channel uint16, class, method uint16, args []byte, m *fakeMessage) {
+	header := &argWriter{}
+	header.short(60)
+	header.short(0)
+	header.longlong(uint64(len(m.body)))
+	if len(m.properties) == 0 {
+		header.short(0)
+	} else {
+		header.Write(m.properties)
+	}
+	frames := [][]byte{methodFrame(channel, class, method, args), frameBytes(2, channel, header.Bytes())}
+	if len(m.body) > 0 {
+		frames = append(frames, frameBytes(3, channel, m.body))
+	}
+	c.send(frames...)
+}
+
+// send queue frames for writing, frames are written in order by write
+func (c *fakeConn) send(frames ...[]byte) {
+	c.outMu.Lock()
+	c.out = append(c.out, frames...)
+	c.outMu.Unlock()
+	select {
+	case c.outCh <- struct{}{}:
+	default:
+	}
+}
+
+// write write queued frames until connection is closed, then it closes the network connection
+func (c *fakeConn) write() {
+	defer c.netConn.Close()
+	for range c.outCh {
+		c.outMu.Lock()
+		out, closed := c.out, c.closed
+		c.out = nil
+		c.outMu.Unlock()
+		for _, f := range out {
+			if _, err := c.netConn.Write(f); err != nil {
+				return
+			}
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+func readFrame(r *bufio.Reader) (byte, uint16, []byte, error) {
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[3:7])
+	payload := make([]byte, size+1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	if payload[size] != 0xCE {
+		return 0, 0, nil, errors.New("invalid frame end")
+	}
+	return header[0], binary.BigEndian.Uint16(header[1:3]), payload[:size], nil
+}
+
+func methodFrame(channel uint16, class, method uint16, args []byte) []byte {
+	w := &argWriter{}
+	w.short(class)
+	w.short(method)
+	w.Write(args)
+	return frameBytes(1, channel, w.Bytes())
+}
+
+func frameBytes(typ byte, channel uint16, payload []byte) []byte {
+	f := make([]byte, 7, len(payload)+8)
+	f[0] = typ
+	binary.BigEndian.PutUint16(f[1:3], channel)
+	binary.BigEndian.PutUint32(f[3:7], uint32(len(payload)))
+	f = append(f, payload...)
+	return append(f, 0xCE)
+}
+
+// argReader read arguments of AMQP methods, consecutive bits are packed in octets
+type argReader struct {
+	b      []byte
+	bits   byte
+	bitPos int
+}
+
+func (r *argReader) next(n int) []byte {
+	r.bitPos = 0
+	if len(r.b) < n {
+		r.b = nil
+		return make([]byte, n)
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *argReader) short() uint16 {
+	return binary.BigEndian.Uint16(r.next(2))
+}
+
+func (r *argReader) longlong() uint64 {
+	return binary.BigEndian.Uint64(r.next(8))
+}
+
+func (r *argReader) shortstr() string {
+	n := int(r.next(1)[0])
+	return string(r.next(n))
+}
+
+func (r *argReader) bit() bool {
+	if r.bitPos == 0 || r.bitPos == 8 {
+		r.bits = r.next(1)[0]
+	}
+	v := r.bits&(1<<r.bitPos) != 0
+	r.bitPos++
+	return v
+}
+
+// argWriter write arguments of AMQP methods
+type argWriter struct {
+	bytes.Buffer
+}
+
+func (w *argWriter) octet(v byte) {
+	w.WriteByte(v)
+}
+
+func (w *argWriter) short(v uint16) {
+	_ = binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *argWriter) long(v uint32) {
+	_ = binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *argWriter) longlong(v uint64) {
+	_ = binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *argWriter) shortstr(s string) {
+	w.WriteByte(byte(len(s)))
+	w.WriteString(s)
+}
+
+func (w *argWriter) longstr(s string) {
+	w.long(uint32(len(s)))
+	w.WriteString(s)
+}
+
+// table write empty field table
+func (w *argWriter) table() {
+	w.long(0)
+}
+
+func (w *argWriter) bits(bits ...bool) {
+	var v byte
+	for i, b := range bits {
+		if b {
+			v |= 1 << i
+		}
+	}
+	w.WriteByte(v)
+}
@@ -0,0 +1,107 @@
+package rabbitmq
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Confirmation is the rabbitMQ confirmation of a published event
+type Confirmation struct {
+	DeliveryTag uint64 // DeliveryTag of the event on the publishing channel
+	Ack         bool   // Ack is true when rabbitMQ persisted the event
+	Err         error  // Err is set when the channel closed before the confirmation received
+}
+
+// PublishWithConfirm publishes a bson encoded event and blocks until rabbitMQ acknowledge it or ctx done,
+// if rabbitMQ blocked the publishing connection it waits for unblocking until ctx done
+func (c *Connection) PublishWithConfirm(ctx context.Context, exchange, routingKey string, body interface{}, publishOptions PublishingOptions) error {
+	if err := c.waitUnblocked(ctx); err != nil {
+		return err
+	}
+	confirm, err := c.publishAsync(ctx, exchange, routingKey, body, publishOptions)
+	if err != nil {
+		return err
+	}
+	return waitConfirmation(ctx, confirm)
+}
+
+// PublishAsync publishes a bson encoded event and returns a channel which receive its confirmation
+func (c *Connection) PublishAsync(exchange, routingKey string, body interface{}, publishOptions PublishingOptions) (<-chan Confirmation, error) {
+	return c.publishAsync(context.Background(), exchange, routingKey, body, publishOptions)
+}
+
+// publishAsync encode body with bson and publishes it in confirm mode
+func (c *Connection) publishAsync(ctx context.Context, exchange, routingKey string, body interface{}, publishOptions PublishingOptions) (<-chan Confirmation, error) {
+	b, err := bson.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(publishOptions.ContentType) == 0 {
+		publishOptions.ContentType = BSON_CONTENT_TYPE
+	}
+	return c.publishAsyncBytes(ctx, exchange, routingKey, b, publishOptions)
+}
+
+// PublishWithConfirm encodes v with the connection encoder, publishes it and blocks until rabbitMQ acknowledge it or ctx done
+func (c *EncodedConn) PublishWithConfirm(ctx context.Context, exchange, routingKey string, v interface{}, publishOptions PublishingOptions) error {
+	if err := c.Conn.waitUnblocked(ctx); err != nil {
+		return err
+	}
+	confirm, err := c.publishAsync(ctx, exchange, routingKey, v, publishOptions)
+	if err != nil {
+		return err
+	}
+	return waitConfirmation(ctx, confirm)
+}
+
+// PublishAsync encodes v with the connection encoder, publishes it and returns a channel which receive its confirmation
+func (c *EncodedConn) PublishAsync(exchange, routingKey string, v interface{}, publishOptions PublishingOptions) (<-chan Confirmation, error) {
+	return c.publishAsync(context.Background(), exchange, routingKey, v, publishOptions)
+}
+
+// publishAsync encode v with the connection encoder and publishes it in confirm mode
+func (c *EncodedConn) publishAsync(ctx context.Context, exchange, routingKey string, v interface{}, publishOptions PublishingOptions) (<-chan Confirmation, error) {
+	b, err := c.Enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(publishOptions.ContentType) == 0 {
+		publishOptions.ContentType = c.ContentType
+	}
+	return c.Conn.publishAsyncBytes(ctx, exchange, routingKey, b, publishOptions)
+}
+
+// publishAsyncBytes publishes serialized event on channel in confirm mode
+func (c *Connection) publishAsyncBytes(ctx context.Context, exchange, routingKey string, b []byte, publishOptions PublishingOptions) (<-chan Confirmation, error) {
+	if !c.ConnOpt.ConfirmMode {
+		return nil, CONFIRM_MODE_DISABLED_ERROR
+	}
+	if !c.hasExchange(exchange) {
+		return nil, EXHCNAGE_NOT_FOUND_ERROR
+	}
+	confirm, err := c.publishWithMiddlewares(ctx, exchange, routingKey, b, publishOptions)
+	if err == nil && confirm == nil {
+		// a publish middleware did not publish the event
+		skipped := make(chan Confirmation, 1)
+		skipped <- Confirmation{Ack: true}
+		return skipped, nil
+	}
+	return confirm, err
+}
+
+// waitConfirmation wait for confirmation and convert it to error
+func waitConfirmation(ctx context.Context, confirm <-chan Confirmation) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case c := <-confirm:
+		if c.Err != nil {
+			return c.Err
+		}
+		if !c.Ack {
+			return PUBLISH_NACKED_ERROR
+		}
+		return nil
+	}
+}
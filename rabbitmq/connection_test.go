@@ -0,0 +1,305 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestOperationsDuringReconnect publish, declare and consume concurrently while connections are dropped,
+// it is meant to run with -race
+func TestOperationsDuringReconnect(t *testing.T) {
+	for _, separate := range []bool{false, true} {
+		t.Run(fmt.Sprintf("separate connections %v", separate), func(t *testing.T) {
+			broker := newFakeBroker(t, nil)
+			conn := newTestConnection(t, broker, &Options{ConfirmMode: true, SeparateConnections: separate})
+			var consumed int64
+			if err := conn.ExchangeDeclare("ex", DIRECT); err != nil {
+				t.Fatal(err)
+			}
+			err := conn.DeclareConsumerQueue(func(queue string, delivery Delivery) {
+				atomic.AddInt64(&consumed, 1)
+			}, "q", "ex", "rk")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := conn.Consume(); err != nil {
+				t.Fatal(err)
+			}
+
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			run := func(operation func(i int)) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; ; i++ {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						operation(i)
+						time.Sleep(time.Millisecond)
+					}
+				}()
+			}
+			for i := 0; i < 4; i++ {
+				run(func(int) {
+					ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+					defer cancel()
+					_ = conn.PublishWithConfirm(ctx, "ex", "rk", map[string]string{"a": "b"}, PublishingOptions{})
+				})
+			}
+			run(func(i int) {
+				exchange := fmt.Sprintf("ex-%d", i%8)
+				_ = conn.ExchangeDeclare(exchange, TOPIC)
+				_ = conn.DeclarePublisherQueue(fmt.Sprintf("pq-%d", i%8), exchange, "#")
+			})
+			run(func(i int) {
+				if i%10 == 0 {
+					_ = conn.DeclareConsumerQueue(func(string, Delivery) {}, fmt.Sprintf("cq-%d", i/10%4), "ex", "rk")
+					_ = conn.Consume()
+				}
+			})
+			run(func(int) {
+				conn.IsConnected()
+				conn.CurrentNode()
+				conn.CurrentPublisherNode()
+				conn.GetConsumerState("q")
+				conn.GetExchangeList()
+				conn.GetQueueList()
+			})
+
+			for i := 0; i < 5; i++ {
+				time.Sleep(50 * time.Millisecond)
+				broker.dropConnections()
+			}
+			close(stop)
+			wg.Wait()
+
+			eventually(t, 3*time.Second, func() bool {
+				return conn.IsConnected() && conn.GetConsumerState("q") == CONSUMER_RUNNING
+			}, "connection is not recovered after reconnect")
+			before := atomic.LoadInt64(&consumed)
+			eventually(t, 3*time.Second, func() bool {
+				_ = conn.Publish("ex", "rk", map[string]string{"a": "b"}, PublishingOptions{})
+				return atomic.LoadInt64(&consumed) > before
+			}, "events are not consumed after reconnect")
+		})
+	}
+}
+
+func TestShutdownWaitsForHandlers(t *testing.T) {
+	broker := newFakeBroker(t, nil)
+	conn := newTestConnection(t, broker, nil)
+	started, release := make(chan struct{}), make(chan struct{})
+	var handled int64
+	if err := conn.ExchangeDeclare("ex", DIRECT); err != nil {
+		t.Fatal(err)
+	}
+	err := conn.DeclareConsumerQueue(func(queue string, delivery Delivery) {
+		close(started)
+		<-release
+		atomic.AddInt64(&handled, 1)
+	}, "q", "ex", "rk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Consume(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Publish("ex", "rk", map[string]string{"a": "b"}, PublishingOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := conn.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+	if atomic.LoadInt64(&handled) != 1 {
+		t.Fatal("Shutdown returned before in-flight handler finished")
+	}
+	if state := conn.GetConsumerState("q"); state != CONSUMER_STOPPED {
+		t.Fatalf("consumer state is %v after Shutdown, want %v", state, CONSUMER_STOPPED)
+	}
+}
+
+func TestShutdownReportsInFlightHandlers(t *testing.T) {
+	broker := newFakeBroker(t, nil)
+	conn := newTestConnection(t, broker, nil)
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	if err := conn.ExchangeDeclare("ex", DIRECT); err != nil {
+		t.Fatal(err)
+	}
+	err := conn.DeclareConsumerQueue(func(queue string, delivery Delivery) {
+		close(started)
+		<-release
+	}, "q", "ex", "rk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Consume(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Publish("ex", "rk", map[string]string{"a": "b"}, PublishingOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = conn.Shutdown(ctx)
+	var shutdownErr *ShutdownError
+	if !errors.As(err, &shutdownErr) {
+		t.Fatalf("Shutdown returned %v, want *ShutdownError", err)
+	}
+	if shutdownErr.InFlightHandlers["q"] != 1 {
+		t.Fatalf("in-flight handlers of q are %d, want 1", shutdownErr.InFlightHandlers["q"])
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown returned %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestInitialConnectUsesReconnectPolicy(t *testing.T) {
+	// address of a closed port which refuses connecting
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	var delays []time.Duration
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err = NewConnectionContext(ctx, "test", &Options{
+		UriAddress:           "amqp://guest:guest@" + addr + "/",
+		Backoff:              ConstantBackoff{Delay: 10 * time.Millisecond},
+		ReconnectMaxAttempts: 3,
+		ReconnectHandler:     func(attempt int, delay time.Duration, err error) { delays = append(delays, delay) },
+		Logger:               NopLogger{},
+	})
+	if !errors.Is(err, RECONNECT_FAILED_ERROR) {
+		t.Fatalf("NewConnectionContext returned %v, want %v", err, RECONNECT_FAILED_ERROR)
+	}
+	if len(delays) != 3 || delays[0] != 10*time.Millisecond || delays[2] != 0 {
+		t.Fatalf("ReconnectHandler is called with delays %v, want two attempts with 10ms delay and the last one without delay", delays)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("connecting took %v, Backoff is not used", elapsed)
+	}
+}
+
+func TestPublishWithContextStopsWaitingForReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+	conn, err := NewConnectionContext(context.Background(), "test", &Options{
+		UriAddress: "amqp://guest:guest@" + addr + "/",
+		Lazy:       true,
+		Backoff:    ConstantBackoff{Delay: 20 * time.Millisecond},
+		Logger:     NopLogger{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.ExchangeDeclare("ex", DIRECT); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = conn.PublishWithContext(ctx, "ex", "rk", map[string]string{"a": "b"}, PublishingOptions{})
+	if !errors.Is(err, CONNECTION_CLOSED_ERROR) {
+		t.Fatalf("publishing while disconnected returned %v, want %v", err, CONNECTION_CLOSED_ERROR)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("publishing returned after %v, ctx is not used for waiting", elapsed)
+	}
+}
+
+func TestShutdownRequeuesDelayedEvents(t *testing.T) {
+	broker := newFakeBroker(t, nil)
+	var mu sync.Mutex
+	var errs []error
+	conn := newTestConnection(t, broker, &Options{ErrorHandler: func(queue string, delivery Delivery, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}})
+	if err := conn.ExchangeDeclare("ex", DIRECT); err != nil {
+		t.Fatal(err)
+	}
+	handled := make(chan struct{}, 1)
+	handlerErr := errors.New("failed")
+	err := conn.DeclareAckConsumerQueue(func(ctx context.Context, queue string, delivery Delivery) error {
+		handled <- struct{}{}
+		return handlerErr
+	}, &ConsumerOptions{FailurePolicy: RETRY_LATER_ON_FAILURE, RetryDelay: 200 * time.Millisecond}, "q", "ex", "rk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Consume(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Publish("ex", "rk", map[string]string{"a": "b"}, PublishingOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	<-handled
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := conn.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+	// the timer of the delayed requeue would fire after closing
+	time.Sleep(300 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 1 || !errors.Is(errs[0], handlerErr) {
+		t.Fatalf("ErrorHandler received %v, want only the handler error", errs)
+	}
+	if n := broker.messageCount("q"); n != 1 {
+		t.Fatalf("%d events are in queue after Shutdown, want the failed event requeued", n)
+	}
+}
+
+func TestConsumerRestartUsesBackoff(t *testing.T) {
+	broker := newFakeBroker(t, nil)
+	var consumeErrs int64
+	conn := newTestConnection(t, broker, &Options{
+		ErrorHandler: func(queue string, delivery Delivery, err error) { atomic.AddInt64(&consumeErrs, 1) },
+	})
+	if err := conn.ExchangeDeclare("ex", DIRECT); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.DeclareConsumerQueue(func(string, Delivery) {}, "q", "ex", "rk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Consume(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, 2*time.Second, func() bool { return conn.GetConsumerState("q") == CONSUMER_RUNNING }, "consumer is not started")
+
+	// consuming the deleted queue fails until it is declared again
+	broker.deleteQueue("q")
+	eventually(t, 2*time.Second, func() bool { return atomic.LoadInt64(&consumeErrs) >= 3 }, "consuming is not retried by Backoff")
+	broker.mu.Lock()
+	broker.queue("q")
+	broker.bindings = append(broker.bindings, fakeBinding{queue: "q", exchange: "ex", key: "rk"})
+	broker.mu.Unlock()
+	eventually(t, 2*time.Second, func() bool { return conn.GetConsumerState("q") == CONSUMER_RUNNING }, "consumer is not restarted")
+}
@@ -1,47 +1,444 @@
 package rabbitmq
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// ConsumerState is the state of the consumer of a queue
+type ConsumerState int
+
+const (
+	CONSUMER_STOPPED      ConsumerState = iota // CONSUMER_STOPPED consumer is not started or the connection is closed
+	CONSUMER_RUNNING                           // CONSUMER_RUNNING consumer receives events of the queue
+	CONSUMER_RECONNECTING                      // CONSUMER_RECONNECTING consumer waits for rabbitMQ to consume the queue again
 )
 
-//Consume consumes the events from the queues and passes it as map of chan amqp.Delivery
+const (
+	defaultRetryDelay = 5 * time.Second
+	defaultWorkers    = 1
+)
+
+// ConsumerOptions options of consumer queue. FailurePolicy, RetryDelay and Retry are applied by the library when it
+// acknowledges events, i.e. to queues of DeclareAckConsumerQueue and EncodedConn.Subscribe, and to panicked handlers of
+// DeclareConsumerQueueWithOptions without Options.AutoAck
+type ConsumerOptions struct {
+	FailurePolicy FailurePolicy      // FailurePolicy applied to events whose handler returned error or panicked
+	RetryDelay    time.Duration      // RetryDelay delay before requeue with RETRY_LATER_ON_FAILURE or when Retry cannot publish the event, default is 5 seconds
+	Retry         *RetryOptions      // Retry retries failed events through delay queues instead of applying FailurePolicy
+	DeadLetter    *DeadLetterOptions // DeadLetter declare dead letter exchange and queue for rejected and expired events of the queue
+	Workers       int                // Workers number of goroutines handling events of the queue concurrently, default is 1
+	PrefetchCount int                // PrefetchCount maximum number of unacknowledged events delivered to the consumer, 0 is unlimited, ignored with AutoAck
+	PrefetchSize  int                // PrefetchSize maximum size in bytes of unacknowledged events delivered to the consumer, 0 is unlimited
+	OrderingKey   OrderingKey        // OrderingKey events with the same key are handled sequentially by one worker, while different keys are handled in parallel
+}
+
+// OrderingKey extract the ordering key of an event, events with the same key are handled in order
+type OrderingKey func(delivery Delivery) string
+
+// HeaderOrderingKey use the value of the header as ordering key
+func HeaderOrderingKey(header string) OrderingKey {
+	return func(delivery Delivery) string {
+		if v, ok := delivery.Headers[header]; ok {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+}
+
+// CorrelationIdOrderingKey use the CorrelationId of events as ordering key
+func CorrelationIdOrderingKey(delivery Delivery) string {
+	return delivery.CorrelationId
+}
+
+// consumerQueue options of queue declared with consumer options
+type consumerQueue struct {
+	opts      *ConsumerOptions
+	manualAck bool            // manualAck events are acknowledged by the library instead of AutoAck
+	handler   AckEventHandler // handler of manualAck queues called with the context of consumer span instead of EventHandler
+}
+
+// consumer supervise consuming events of a queue on its own channel
+type consumer struct {
+	queue      string
+	tag        string
+	handler    EventHandler
+	ackHandler AckEventHandler // ackHandler is called instead of handler for queues declared by DeclareAckConsumerQueue
+	opts       *ConsumerOptions
+	autoAck    bool
+	inFlight   int64 // inFlight number of received events which are not handled yet
+	mu         sync.Mutex
+	state      ConsumerState
+	channel    *amqp.Channel // channel current consuming channel
+	stopping   bool
+	stop       chan struct{}                // stop closed when consumer is cancelled by Shutdown
+	requeues   map[*delayedRequeue]struct{} // requeues failed events waiting for requeue after RetryDelay, guarded by mu
+}
+
+// delayedRequeue is a failed event which is requeued by timer after RetryDelay
+type delayedRequeue struct {
+	timer    *time.Timer
+	delivery Delivery
+}
+
+// Consume consumes the events from the queues and passes them to the event handler of each queue.
+// Every consumer is re-established on a new channel after reconnect, errors of consuming the queue
+// again are reported to Options.ErrorHandler. Consumers wait for connecting when the connection is closed.
+// Calling Consume again starts only new consumer queues.
 func (c *Connection) Consume() error {
+	var firstErr error
+	for _, cs := range c.newConsumers() {
+		deliveries, err := c.openConsumer(cs)
+		if err != nil && !errors.Is(err, CONNECTION_CLOSED_ERROR) {
+			c.mu.Lock()
+			delete(c.consumers, cs.queue)
+			c.mu.Unlock()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("cannot consume queue %v: %w", cs.queue, err)
+			}
+			continue
+		}
+		c.consumersWg.Add(1)
+		go c.superviseConsumer(cs, deliveries)
+	}
+	return firstErr
+}
+
+// newConsumers register consumers of consumer queues which are not consuming
+func (c *Connection) newConsumers() []*consumer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var consumers []*consumer
 	for queue, handler := range c.queues {
-		queue := queue
-		handler := handler
-		go func() {
-			c.consume(queue, handler)
-			if r := recover(); r != nil {
-				log.Printf("consumer got panic %v and recovered", r)
+		if handler == nil {
+			// publisher queue
+			continue
+		}
+		if _, ok := c.consumers[queue]; ok {
+			continue
+		}
+		cs := &consumer{
+			queue:    queue,
+			tag:      fmt.Sprintf("%s.%s", c.ServiceCallerName, queue),
+			handler:  handler,
+			opts:     validateConsumerOptions(nil),
+			autoAck:  c.ConnOpt.AutoAck,
+			stop:     make(chan struct{}),
+			requeues: make(map[*delayedRequeue]struct{}),
+		}
+		if cq, ok := c.consumerQueues[queue]; ok {
+			cs.opts = cq.opts
+			cs.autoAck = c.ConnOpt.AutoAck && !cq.manualAck
+			cs.ackHandler = cq.handler
+		}
+		c.consumers[queue] = cs
+		consumers = append(consumers, cs)
+	}
+	return consumers
+}
+
+// GetConsumerState return state of the consumer of queue
+func (c *Connection) GetConsumerState(queue string) ConsumerState {
+	c.mu.RLock()
+	cs, ok := c.consumers[queue]
+	c.mu.RUnlock()
+	if !ok {
+		return CONSUMER_STOPPED
+	}
+	return cs.getState()
+}
+
+// superviseConsumer pass deliveries to the workers of the consumer and consume the queue again when the channel closed
+func (c *Connection) superviseConsumer(cs *consumer, deliveries <-chan amqp.Delivery) {
+	defer c.consumersWg.Done()
+	var workers sync.WaitGroup
+	lanes := c.startWorkers(cs, &workers)
+	defer func() {
+		for _, lane := range lanes {
+			close(lane)
+		}
+		workers.Wait()
+	}()
+	for {
+		if deliveries == nil {
+			cs.setState(CONSUMER_RECONNECTING)
+			var ok bool
+			if deliveries, ok = c.restartConsumer(cs); !ok {
+				cs.setState(CONSUMER_STOPPED)
+				return
 			}
-		}()
+		}
+		cs.setState(CONSUMER_RUNNING)
+		for msg := range deliveries {
+			delivery := Delivery(msg)
+			lane := lanes[0]
+			if cs.opts.OrderingKey != nil {
+				lane = lanes[laneIndex(cs.opts.OrderingKey(delivery), len(lanes))]
+			}
+			atomic.AddInt64(&cs.inFlight, 1)
+			c.metrics.Consumed(c.ServiceCallerName, cs.queue)
+			c.metrics.InFlight(c.ServiceCallerName, cs.queue, 1)
+			lane <- delivery
+		}
+		if cs.isStopping() {
+			cs.setState(CONSUMER_STOPPED)
+			return
+		}
+		deliveries = nil
+	}
+}
+
+// startWorkers start workers of the consumer and return their lanes, with OrderingKey each worker has its own lane
+// otherwise all workers receive from one lane
+func (c *Connection) startWorkers(cs *consumer, workers *sync.WaitGroup) []chan Delivery {
+	work := func(lane chan Delivery) {
+		defer workers.Done()
+		for delivery := range lane {
+			c.dispatch(cs, delivery)
+		}
+	}
+	if cs.opts.OrderingKey == nil {
+		lane := make(chan Delivery)
+		for i := 0; i < cs.opts.Workers; i++ {
+			workers.Add(1)
+			go work(lane)
+		}
+		return []chan Delivery{lane}
+	}
+	lanes := make([]chan Delivery, cs.opts.Workers)
+	for i := range lanes {
+		lanes[i] = make(chan Delivery)
+		workers.Add(1)
+		go work(lanes[i])
+	}
+	return lanes
+}
+
+// laneIndex hash key to one of n lanes
+func laneIndex(key string, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// dispatch pass delivery to the event handler, panic of the handler is recovered and
+// the event is negatively acknowledged when the consumer is not auto ack
+func (c *Connection) dispatch(cs *consumer, delivery Delivery) {
+	defer atomic.AddInt64(&cs.inFlight, -1)
+	defer c.metrics.InFlight(c.ServiceCallerName, cs.queue, -1)
+	defer func(start time.Time) {
+		c.metrics.HandlerDuration(c.ServiceCallerName, cs.queue, time.Since(start))
+	}(time.Now())
+	ctx, end := c.tracer.StartConsume(c.ctx, cs.queue, delivery)
+	var err error
+	defer func() {
+		end(err)
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", HANDLER_PANIC_ERROR, r)
+			if cs.autoAck {
+				c.handleError(cs.queue, delivery, err)
+				return
+			}
+			c.handleFailure(cs.opts, cs.queue, delivery, err)
+		}
+	}()
+	handler := cs.handler
+	if cs.ackHandler != nil {
+		handler = func(queue string, delivery Delivery) {
+			err = cs.ackHandler(ctx, queue, delivery)
+		}
+	}
+	c.consumeChain(cs.queue, handler)(cs.queue, delivery)
+}
+
+// restartConsumer wait for rabbitMQ connection and consume the queue on a new channel, failed attempts are retried
+// by Options.Backoff. It returns false when the connection closed by Close or the consumer cancelled by Shutdown
+func (c *Connection) restartConsumer(cs *consumer) (<-chan amqp.Delivery, bool) {
+	attempt := 0
+	for {
+		connected, alive, changed := c.connectionState()
+		if !alive || cs.isStopping() {
+			return nil, false
+		}
+		if !connected {
+			select {
+			case <-changed:
+			case <-cs.stop:
+			}
+			continue
+		}
+		deliveries, err := c.openConsumer(cs)
+		if err == nil {
+			return deliveries, true
+		}
+		if errors.Is(err, CONSUMER_STOPPED_ERROR) {
+			return nil, false
+		}
+		if !errors.Is(err, CONNECTION_CLOSED_ERROR) {
+			c.handleError(cs.queue, Delivery{}, fmt.Errorf("cannot consume queue %v: %w", cs.queue, err))
+		}
+		attempt++
+		select {
+		case <-changed:
+		case <-cs.stop:
+		case <-time.After(c.ConnOpt.Backoff.Next(attempt)):
+		}
 	}
-	return nil
 }
 
-func (c *Connection) consume(queue string, eventHandler EventHandler) {
-	deliveries, _ := c.channel.Consume(queue,
-		"",
-		c.ConnOpt.AutoAck,
+// openConsumer open a channel on the current connection and start consuming the queue
+func (c *Connection) openConsumer(cs *consumer) (<-chan amqp.Delivery, error) {
+	conn, connected, _ := c.consumerSession.state()
+	if !connected || conn.IsClosed() {
+		return nil, CONNECTION_CLOSED_ERROR
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		if errors.Is(err, amqp.ErrClosed) {
+			return nil, CONNECTION_CLOSED_ERROR
+		}
+		return nil, err
+	}
+	if cs.opts.PrefetchCount > 0 || cs.opts.PrefetchSize > 0 {
+		if err := channel.Qos(cs.opts.PrefetchCount, cs.opts.PrefetchSize, false); err != nil {
+			_ = channel.Close()
+			return nil, err
+		}
+	}
+	deliveries, err := channel.Consume(cs.queue,
+		cs.tag,
+		cs.autoAck,
 		c.ConnOpt.ExclusiveQueue,
 		false,
 		c.ConnOpt.NoWait,
 		nil)
-	for {
-		select {
-		case msg := <-deliveries:
-			if len(msg.Body) != 0 {
-				eventHandler(queue, Delivery(msg))
-			}
-		case <-c.notifyClose:
-			for {
-				if c.isConnected {
-					c.consume(queue, eventHandler)
-					break
-				}
-				time.Sleep(1 * time.Second)
-			}
+	if err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.stopping {
+		_ = channel.Close()
+		return nil, CONSUMER_STOPPED_ERROR
+	}
+	cs.channel = channel
+	return deliveries, nil
+}
+
+// consumerQueueDeclare declare new consumer queue with its dead letter and retry queues
+func (c *Connection) consumerQueueDeclare(eventHandler EventHandler, cq *consumerQueue, queue, exchange string, routingKey ...string) error {
+	opts := cq.opts
+	if c.hasQueue(queue) {
+		return QUEUE_ALREADY_EXISTS_ERROR
+	}
+	var args amqp.Table
+	if opts.DeadLetter != nil {
+		opts.DeadLetter = validateDeadLetterOptions(queue, opts.DeadLetter)
+		if err := c.declareDeadLetter(opts.DeadLetter); err != nil {
+			return err
 		}
+		args = opts.DeadLetter.queueArgs()
+	}
+	if err := c.queueDeclare(eventHandler, cq, queue, exchange, args, routingKey...); err != nil {
+		return err
+	}
+	if opts.Retry != nil {
+		return c.declareRetryQueues(queue, opts.Retry)
+	}
+	return nil
+}
+
+// validateConsumerOptions fill default values of consumer options
+func validateConsumerOptions(opts *ConsumerOptions) *ConsumerOptions {
+	consumerOpts := &ConsumerOptions{}
+	if opts != nil {
+		*consumerOpts = *opts
+	}
+	if consumerOpts.RetryDelay <= 0 {
+		consumerOpts.RetryDelay = defaultRetryDelay
+	}
+	if consumerOpts.Retry != nil {
+		consumerOpts.Retry = validateRetryOptions(consumerOpts.Retry)
+	}
+	if consumerOpts.Workers <= 0 {
+		consumerOpts.Workers = defaultWorkers
+	}
+	return consumerOpts
+}
+
+// cancel stop receiving new events of the queue, events which are handling by workers are not interrupted
+func (cs *consumer) cancel() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.stopping {
+		return
+	}
+	cs.stopping = true
+	close(cs.stop)
+	if cs.channel != nil {
+		_ = cs.channel.Cancel(cs.tag, false)
+	}
+}
+
+// removeRequeue unregister the delayed requeue when its timer fires, it returns false if Shutdown already requeued it
+func (cs *consumer) removeRequeue(r *delayedRequeue) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if _, ok := cs.requeues[r]; !ok {
+		return false
+	}
+	delete(cs.requeues, r)
+	return true
+}
+
+// takeRequeues stop timers of delayed requeues and return their events
+func (cs *consumer) takeRequeues() []Delivery {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	deliveries := make([]Delivery, 0, len(cs.requeues))
+	for r := range cs.requeues {
+		r.timer.Stop()
+		deliveries = append(deliveries, r.delivery)
+		delete(cs.requeues, r)
+	}
+	return deliveries
+}
+
+func (cs *consumer) isStopping() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.stopping
+}
+
+func (cs *consumer) setState(state ConsumerState) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.state = state
+}
+
+func (cs *consumer) getState() ConsumerState {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.state
+}
+
+// String consumer state as string
+func (s ConsumerState) String() string {
+	switch s {
+	case CONSUMER_RUNNING:
+		return "running"
+	case CONSUMER_RECONNECTING:
+		return "reconnecting"
+	default:
+		return "stopped"
 	}
 }
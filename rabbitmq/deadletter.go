@@ -0,0 +1,162 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/streadway/amqp"
+)
+
+// DeadLetterOptions options of dead letter exchange and queue of a queue, rejected and expired
+// events of the queue are routed by rabbitMQ to the dead letter exchange and stored in the dead letter queue
+type DeadLetterOptions struct {
+	Exchange   string // Exchange dead letter exchange, default is <queue>.dlx
+	RoutingKey string // RoutingKey routing key of dead-lettered events, default is the queue name
+	Queue      string // Queue dead letter queue bound to Exchange with RoutingKey, default is <queue>.dlq
+}
+
+// DeadLetterFilter select dead-lettered events for replay
+type DeadLetterFilter func(delivery Delivery) bool
+
+// DeadLetterExchangeName return default dead letter exchange name of queue
+func DeadLetterExchangeName(queue string) string {
+	return fmt.Sprintf("%s.dlx", queue)
+}
+
+// DeadLetterQueueName return default dead letter queue name of queue
+func DeadLetterQueueName(queue string) string {
+	return fmt.Sprintf("%s.dlq", queue)
+}
+
+// queueArgs return arguments of the queue for dead-lettering its events
+func (d *DeadLetterOptions) queueArgs() amqp.Table {
+	return amqp.Table{
+		"x-dead-letter-exchange":    d.Exchange,
+		"x-dead-letter-routing-key": d.RoutingKey,
+	}
+}
+
+// declareDeadLetter declare dead letter exchange and dead letter queue bound to it
+func (c *Connection) declareDeadLetter(opts *DeadLetterOptions) error {
+	if !c.hasExchange(opts.Exchange) {
+		if err := c.ExchangeDeclare(opts.Exchange, DIRECT); err != nil && !errors.Is(err, EXCHANGE_ALREADY_EXISTS_ERROR) {
+			return err
+		}
+	}
+	if c.hasQueue(opts.Queue) {
+		return nil
+	}
+	if err := c.queueDeclare(nil, nil, opts.Queue, opts.Exchange, nil, opts.RoutingKey); err != nil && !errors.Is(err, QUEUE_ALREADY_EXISTS_ERROR) {
+		return err
+	}
+	return nil
+}
+
+// ReplayDeadLetters moves events of the dead letter queue dlq to the exchange and routing key they were
+// dead-lettered from, based on the x-death header. When targetExchange is not empty events are published
+// to it with their original routing key. Only events accepted by filter are moved, nil filter accepts all
+// events, and at most limit events are moved when limit is positive. Events are published as mandatory, events
+// which are not routed to any queue are returned by rabbitMQ and stay in dlq like events that are not moved.
+// It returns the number of moved events.
+func (c *Connection) ReplayDeadLetters(dlq, targetExchange string, filter DeadLetterFilter, limit int) (int, error) {
+	conn, connected, _ := c.consumerSession.state()
+	if !connected {
+		return 0, CONNECTION_CLOSED_ERROR
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		return 0, err
+	}
+	// events which are not acknowledged are returned to dlq on closing channel
+	defer channel.Close()
+	if err := channel.Confirm(false); err != nil {
+		return 0, err
+	}
+	confirms := channel.NotifyPublish(make(chan amqp.Confirmation, 1))
+	// rabbitMQ sends the return of an unroutable event before its confirmation
+	returns := channel.NotifyReturn(make(chan amqp.Return, 1))
+	q, err := channel.QueueInspect(dlq)
+	if err != nil {
+		return 0, err
+	}
+	replayed := 0
+	// only events which are in dlq now are inspected, events which are dead-lettered again are not replayed twice
+	for i := 0; i < q.Messages; i++ {
+		if limit > 0 && replayed >= limit {
+			break
+		}
+		msg, ok, err := channel.Get(dlq, false)
+		if err != nil {
+			return replayed, err
+		}
+		if !ok {
+			break
+		}
+		delivery := Delivery(msg)
+		if filter != nil && !filter(delivery) {
+			continue
+		}
+		exchange, routingKey := deadLetterOrigin(delivery)
+		if len(targetExchange) != 0 {
+			exchange = targetExchange
+		}
+		publishOptions := publishingOptionsFromDelivery(delivery)
+		publishOptions.Mandatory = true
+		if err := publish(channel, exchange, routingKey, delivery.Body, publishOptions); err != nil {
+			return replayed, err
+		}
+		if confirm, ok := <-confirms; !ok {
+			return replayed, CONNECTION_CLOSED_ERROR
+		} else if !confirm.Ack {
+			return replayed, PUBLISH_NACKED_ERROR
+		}
+		select {
+		case <-returns:
+			// event is not acknowledged so it stays in dlq
+			continue
+		default:
+		}
+		if err := msg.Ack(false); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+// deadLetterOrigin return exchange and routing key of the event before it dead-lettered, from the latest x-death entry
+func deadLetterOrigin(delivery Delivery) (string, string) {
+	exchange, routingKey := delivery.Exchange, delivery.RoutingKey
+	deaths, ok := delivery.Headers["x-death"].([]interface{})
+	if !ok || len(deaths) == 0 {
+		return exchange, routingKey
+	}
+	death, ok := deaths[0].(amqp.Table)
+	if !ok {
+		return exchange, routingKey
+	}
+	if e, ok := death["exchange"].(string); ok {
+		exchange = e
+	}
+	if keys, ok := death["routing-keys"].([]interface{}); ok && len(keys) != 0 {
+		if k, ok := keys[0].(string); ok {
+			routingKey = k
+		}
+	}
+	return exchange, routingKey
+}
+
+// validateDeadLetterOptions fill default values of dead letter options of queue
+func validateDeadLetterOptions(queue string, opts *DeadLetterOptions) *DeadLetterOptions {
+	deadLetterOpts := *opts
+	if len(deadLetterOpts.Exchange) == 0 {
+		deadLetterOpts.Exchange = DeadLetterExchangeName(queue)
+	}
+	if len(deadLetterOpts.RoutingKey) == 0 {
+		deadLetterOpts.RoutingKey = queue
+	}
+	if len(deadLetterOpts.Queue) == 0 {
+		deadLetterOpts.Queue = DeadLetterQueueName(queue)
+	}
+	return &deadLetterOpts
+}
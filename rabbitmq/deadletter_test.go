@@ -0,0 +1,30 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplayDeadLettersKeepsReturnedEvents(t *testing.T) {
+	broker := newFakeBroker(t, nil)
+	conn := newTestConnection(t, broker, nil)
+	if err := conn.ExchangeDeclare("ex", DIRECT); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.DeclarePublisherQueue("q", "ex", "rk"); err != nil {
+		t.Fatal(err)
+	}
+	broker.enqueue("q.dlq", &fakeMessage{exchange: "ex", routingKey: "rk", body: []byte("routed")})
+	broker.enqueue("q.dlq", &fakeMessage{exchange: "ex", routingKey: "unbound", body: []byte("unroutable")})
+
+	replayed, err := conn.ReplayDeadLetters("q.dlq", "", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != 1 {
+		t.Fatalf("replayed %d events, want 1", replayed)
+	}
+	eventually(t, 2*time.Second, func() bool {
+		return broker.messageCount("q") == 1 && broker.messageCount("q.dlq") == 1
+	}, "unroutable event is not left in dead letter queue")
+}
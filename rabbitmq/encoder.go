@@ -1,11 +1,16 @@
 package rabbitmq
 
 import (
-	"github.com/ramoozorg/event-driven/rabbitmq/enc"
+	"context"
+	"reflect"
 	"sync"
+
+	"github.com/ramoozorg/event-driven/rabbitmq/enc"
 )
 
 var encMap map[string]Encoder
+var contentTypeMap map[string]Encoder // contentTypeMap registered encoders by MIME type
+var encContentTypes map[string]string // encContentTypes MIME type of registered encoder types
 var encLock sync.Mutex
 
 // Encoder interface is for all register encoders
@@ -19,18 +24,53 @@ const (
 	BSON_ENCODER = "bson"
 )
 
+const (
+	JSON_CONTENT_TYPE = "application/json" // JSON_CONTENT_TYPE MIME type of json encoded events
+	BSON_CONTENT_TYPE = "application/bson" // BSON_CONTENT_TYPE MIME type of bson encoded events
+)
+
 func init() {
 	encMap = make(map[string]Encoder)
+	contentTypeMap = make(map[string]Encoder)
+	encContentTypes = make(map[string]string)
 	// Register json, bson encoder
-	RegisterEncoder(JSON_ENCODER, &enc.JsonEncoder{})
-	RegisterEncoder(BSON_ENCODER, &enc.BsonEncoder{})
+	RegisterEncoderWithContentType(JSON_ENCODER, JSON_CONTENT_TYPE, &enc.JsonEncoder{})
+	RegisterEncoderWithContentType(BSON_ENCODER, BSON_CONTENT_TYPE, &enc.BsonEncoder{})
 }
 
+// EncodedConn are the preferred way to interface with rabbitMQ. They wrap a bare connection to
+// a rabbitMQ server and have an extended API for publishing and subscribing typed values.
 type EncodedConn struct {
-	Conn *Connection
-	Enc  Encoder
+	Conn        *Connection
+	Enc         Encoder
+	ContentType string // ContentType MIME type set on published events, empty for custom encoders
 }
 
+// Handler is a specific callback used for Subscribe. It is generalized to
+// an interface{}, but we will discover its format and arguments at runtime
+// and perform the correct callback, including decoding the event body
+// back into the appropriate struct based on the signature of the Handler.
+//
+// Handlers are expected to have one of three signatures.
+//
+//	type person struct {
+//		Name string `bson:"name" json:"name"`
+//		Age  int    `bson:"age" json:"age"`
+//	}
+//
+//	handler := func(p *person) {
+//		fmt.Printf("Received a person: %+v\n", p)
+//	}
+//
+//	handler := func(queue string, p *person) {
+//		fmt.Printf("Received a person on queue %s: %+v\n", queue, p)
+//	}
+//
+//	handler := func(queue string, delivery rabbitmq.Delivery, p *person) {
+//		fmt.Printf("Received a person on queue %s with routingKey %s: %+v\n", queue, delivery.RoutingKey, p)
+//	}
+type Handler interface{}
+
 // RegisterEncoder will register the encType with the given Encoder. Useful for customization.
 func RegisterEncoder(encType string, enc Encoder) {
 	encLock.Lock()
@@ -38,9 +78,165 @@ func RegisterEncoder(encType string, enc Encoder) {
 	encMap[encType] = enc
 }
 
+// RegisterContentType will register the MIME contentType with the given Encoder, consumers use it
+// for decoding events published with this content type.
+func RegisterContentType(contentType string, enc Encoder) {
+	encLock.Lock()
+	defer encLock.Unlock()
+	contentTypeMap[contentType] = enc
+}
+
+// RegisterEncoderWithContentType will register the encType and MIME contentType with the given Encoder,
+// EncodedConn of the encType stamp contentType on published events.
+func RegisterEncoderWithContentType(encType, contentType string, enc Encoder) {
+	encLock.Lock()
+	defer encLock.Unlock()
+	encMap[encType] = enc
+	contentTypeMap[contentType] = enc
+	encContentTypes[encType] = contentType
+}
+
 // EncoderForType will return the registered Encoder for the encType.
 func EncoderForType(encType string) Encoder {
 	encLock.Lock()
 	defer encLock.Unlock()
 	return encMap[encType]
 }
+
+// EncoderForContentType will return the registered Encoder for the MIME contentType.
+func EncoderForContentType(contentType string) Encoder {
+	encLock.Lock()
+	defer encLock.Unlock()
+	return contentTypeMap[contentType]
+}
+
+// ContentTypeForEncoder will return the MIME content type registered for the encType.
+func ContentTypeForEncoder(encType string) string {
+	encLock.Lock()
+	defer encLock.Unlock()
+	return encContentTypes[encType]
+}
+
+// Publish encodes v with the connection encoder and publishes it to the exchange with routingKey
+func (c *EncodedConn) Publish(exchange, routingKey string, v interface{}, publishOptions PublishingOptions) error {
+	return c.PublishWithContext(context.Background(), exchange, routingKey, v, publishOptions)
+}
+
+// PublishWithContext is like Publish, ctx is the parent of the publishing span if Options.Tracer is set and
+// bounds waiting for reconnecting when the publishing connection is closed
+func (c *EncodedConn) PublishWithContext(ctx context.Context, exchange, routingKey string, v interface{}, publishOptions PublishingOptions) error {
+	b, err := c.Enc.Encode(v)
+	if err != nil {
+		return err
+	}
+	if len(publishOptions.ContentType) == 0 {
+		publishOptions.ContentType = c.ContentType
+	}
+	return c.Conn.publishBytes(ctx, exchange, routingKey, b, publishOptions)
+}
+
+// Subscribe declare new consumer queue bound to exchange with routing keys, events of the queue
+// are decoded with the encoder registered for their content type and passed to the Handler.
+// Events without content type are decoded with bson like DecodeDelivery, or with the connection
+// encoder if it is registered without content type. Events are consumed like DeclareAckConsumerQueue,
+// they are acknowledged after the Handler returns and rejected when they cannot be decoded.
+func (c *EncodedConn) Subscribe(queue, exchange string, cb Handler, routingKeys ...string) error {
+	eventHandler, err := c.eventHandler(cb)
+	if err != nil {
+		return err
+	}
+	return c.Conn.DeclareAckConsumerQueue(eventHandler, nil, queue, exchange, routingKeys...)
+}
+
+// eventHandler wraps the Handler into an AckEventHandler which decode the event body before calling it,
+// it returns *DecodeError if the body cannot be decoded
+func (c *EncodedConn) eventHandler(cb Handler) (AckEventHandler, error) {
+	if cb == nil {
+		return nil, HANDLER_INVALID_ERROR
+	}
+	argType, numArgs := argInfo(cb)
+	if argType == nil || numArgs > 3 {
+		return nil, HANDLER_INVALID_ERROR
+	}
+	cbType := reflect.TypeOf(cb)
+	if numArgs > 1 && cbType.In(0).Kind() != reflect.String {
+		return nil, HANDLER_INVALID_ERROR
+	}
+	if numArgs == 3 && cbType.In(1) != reflect.TypeOf(Delivery{}) {
+		return nil, HANDLER_INVALID_ERROR
+	}
+	cbValue := reflect.ValueOf(cb)
+	isPtr := argType.Kind() == reflect.Ptr
+	return func(ctx context.Context, queue string, delivery Delivery) error {
+		var oV []reflect.Value
+		var oPtr reflect.Value
+		if isPtr {
+			oPtr = reflect.New(argType.Elem())
+		} else {
+			oPtr = reflect.New(argType)
+		}
+		enc, err := c.encoderFor(delivery.ContentType)
+		if err == nil {
+			err = enc.Decode(delivery.Body, oPtr.Interface())
+		}
+		if err != nil {
+			return &DecodeError{Queue: queue, ContentType: delivery.ContentType, Err: err}
+		}
+		if !isPtr {
+			oPtr = reflect.Indirect(oPtr)
+		}
+		switch numArgs {
+		case 1:
+			oV = []reflect.Value{oPtr}
+		case 2:
+			oV = []reflect.Value{reflect.ValueOf(queue), oPtr}
+		case 3:
+			oV = []reflect.Value{reflect.ValueOf(queue), reflect.ValueOf(delivery), oPtr}
+		}
+		cbValue.Call(oV)
+		return nil
+	}, nil
+}
+
+// argInfo dissect the Handler's signature
+func argInfo(cb Handler) (reflect.Type, int) {
+	cbType := reflect.TypeOf(cb)
+	if cbType.Kind() != reflect.Func {
+		return nil, 0
+	}
+	numArgs := cbType.NumIn()
+	if numArgs == 0 {
+		return nil, numArgs
+	}
+	return cbType.In(numArgs - 1), numArgs
+}
+
+// encoderFor return the encoder of the event content type, events without content type are bson
+// unless the connection encoder has no content type to stamp on its own events
+func (c *EncodedConn) encoderFor(contentType string) (Encoder, error) {
+	if contentType == c.ContentType {
+		return c.Enc, nil
+	}
+	if enc := encoderForContentType(contentType); enc != nil {
+		return enc, nil
+	}
+	return nil, ENCODER_NOT_FOUND_ERROR
+}
+
+// encoderForContentType return registered Encoder for MIME type, events without content type are bson
+func encoderForContentType(contentType string) Encoder {
+	if len(contentType) == 0 {
+		return EncoderForType(BSON_ENCODER)
+	}
+	return EncoderForContentType(contentType)
+}
+
+// DecodeDelivery decode the body of delivery into vPtr with the encoder registered for its content type,
+// events without content type are decoded with bson
+func DecodeDelivery(delivery Delivery, vPtr interface{}) error {
+	enc := encoderForContentType(delivery.ContentType)
+	if enc == nil {
+		return ENCODER_NOT_FOUND_ERROR
+	}
+	return enc.Decode(delivery.Body, vPtr)
+}
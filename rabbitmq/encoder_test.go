@@ -0,0 +1,126 @@
+package rabbitmq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type testEvent struct {
+	Name string `bson:"name" json:"name"`
+}
+
+// rot1Encoder is an encoder registered without content type
+type rot1Encoder struct{}
+
+func (rot1Encoder) Encode(msg interface{}) ([]byte, error) {
+	b := []byte(msg.(string))
+	for i := range b {
+		b[i]++
+	}
+	return b, nil
+}
+
+func (rot1Encoder) Decode(data []byte, vPtr interface{}) error {
+	b := append([]byte(nil), data...)
+	for i := range b {
+		b[i]--
+	}
+	*vPtr.(*string) = string(b)
+	return nil
+}
+
+func TestEncodedConnDecodesEventsWithoutContentTypeAsBson(t *testing.T) {
+	body, err := bson.Marshal(testEvent{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec, err := NewEncodedConn(&Connection{}, JSON_ENCODER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want testEvent
+	enc, err := ec.encoderFor("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Decode(body, &got); err != nil {
+		t.Fatalf("json EncodedConn cannot decode event without content type: %v", err)
+	}
+	if err := DecodeDelivery(Delivery{Body: body}, &want); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("EncodedConn decoded %+v, DecodeDelivery decoded %+v", got, want)
+	}
+}
+
+func TestEncodedConnDecodesWithEncoderWithoutContentType(t *testing.T) {
+	RegisterEncoder("rot1", rot1Encoder{})
+	ec, err := NewEncodedConn(&Connection{}, "rot1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ec.Enc.Encode("abc")
+	enc, err := ec.encoderFor("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	if err := enc.Decode(body, &got); err != nil || got != "abc" {
+		t.Fatalf("decoded %q, %v, want %q", got, err, "abc")
+	}
+}
+
+func TestSubscribeAcknowledgesEventsAndRejectsUndecodable(t *testing.T) {
+	broker := newFakeBroker(t, nil)
+	decodeErrs := make(chan error, 1)
+	conn := newTestConnection(t, broker, &Options{
+		ErrorHandler: func(queue string, delivery Delivery, err error) { decodeErrs <- err },
+	})
+	ec, err := NewEncodedConn(conn, JSON_ENCODER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.ExchangeDeclare("ex", DIRECT); err != nil {
+		t.Fatal(err)
+	}
+	handled := make(chan testEvent, 1)
+	if err := ec.Subscribe("q", "ex", func(e *testEvent) { handled <- *e }, "rk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Consume(); err != nil {
+		t.Fatal(err)
+	}
+	broker.enqueue("q", &fakeMessage{exchange: "ex", routingKey: "rk", body: []byte("not bson")})
+	if err := ec.Publish("ex", "rk", testEvent{Name: "a"}, PublishingOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-handled:
+		if e.Name != "a" {
+			t.Fatalf("handled %+v, want name a", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("event is not handled")
+	}
+	select {
+	case err := <-decodeErrs:
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Fatalf("ErrorHandler received %v, want *DecodeError", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("decode error is not reported")
+	}
+	// unacknowledged events are requeued when the connection is closed
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, 2*time.Second, func() bool { return broker.openConnections() == 0 }, "connection is not closed")
+	if n := broker.messageCount("q"); n != 0 {
+		t.Fatalf("%d events are left in queue, want handled event acknowledged and undecodable event rejected", n)
+	}
+}
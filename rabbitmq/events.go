@@ -0,0 +1,68 @@
+package rabbitmq
+
+import (
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// ConnectionEventKind is the kind of connection lifecycle event
+type ConnectionEventKind int
+
+const (
+	CONNECTED_EVENT         ConnectionEventKind = iota // CONNECTED_EVENT connected or reconnected to rabbitMQ
+	DISCONNECTED_EVENT                                 // DISCONNECTED_EVENT connection to rabbitMQ closed, Reason is set if rabbitMQ or network closed it
+	RECONNECT_ATTEMPT_EVENT                            // RECONNECT_ATTEMPT_EVENT reconnecting failed, the next attempt is after Delay, Delay is zero on the last attempt
+	TOPOLOGY_RESTORED_EVENT                            // TOPOLOGY_RESTORED_EVENT exchanges and queues are declared again after connecting
+	CLOSED_EVENT                                       // CLOSED_EVENT connection is closed and does not reconnect anymore, Err is set if it failed
+)
+
+// String return name of connection event kind
+func (k ConnectionEventKind) String() string {
+	switch k {
+	case CONNECTED_EVENT:
+		return "connected"
+	case DISCONNECTED_EVENT:
+		return "disconnected"
+	case RECONNECT_ATTEMPT_EVENT:
+		return "reconnect attempt"
+	case TOPOLOGY_RESTORED_EVENT:
+		return "topology restored"
+	case CLOSED_EVENT:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// ConnectionEvent is a lifecycle event of Connection
+type ConnectionEvent struct {
+	Kind    ConnectionEventKind
+	Session string        // Session is publisher, consumer or both of them if connections are not separated
+	Node    string        // Node address of rabbitMQ node on CONNECTED_EVENT
+	Reason  *amqp.Error   // Reason of closing connection on DISCONNECTED_EVENT
+	Attempt int           // Attempt number of failed reconnecting on RECONNECT_ATTEMPT_EVENT
+	Delay   time.Duration // Delay before the next reconnecting on RECONNECT_ATTEMPT_EVENT
+	Err     error         // Err of reconnecting on RECONNECT_ATTEMPT_EVENT or the reason of failed state on CLOSED_EVENT
+}
+
+// ConnectionObserver receive lifecycle events of Connection, it is called synchronously and must not block
+type ConnectionObserver func(event ConnectionEvent)
+
+// Observe register observer of connection lifecycle events, events emitted before registering are not received,
+// use Options.Observers to receive events of the first connecting
+func (c *Connection) Observe(observer ConnectionObserver) {
+	c.observersMu.Lock()
+	defer c.observersMu.Unlock()
+	c.observers = append(c.observers, observer)
+}
+
+// emit send event to observers
+func (c *Connection) emit(event ConnectionEvent) {
+	c.observersMu.RLock()
+	observers := c.observers
+	c.observersMu.RUnlock()
+	for _, observer := range observers {
+		observer(event)
+	}
+}
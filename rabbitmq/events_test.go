@@ -0,0 +1,71 @@
+package rabbitmq
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventRecorder record kinds of connection events
+type eventRecorder struct {
+	mu    sync.Mutex
+	kinds []ConnectionEventKind
+}
+
+func (r *eventRecorder) observe(event ConnectionEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.kinds = append(r.kinds, event.Kind)
+}
+
+func (r *eventRecorder) received(kind ConnectionEventKind) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range r.kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func TestObserversReceiveFirstConnecting(t *testing.T) {
+	for _, lazy := range []bool{false, true} {
+		broker := newFakeBroker(t, nil)
+		recorder := &eventRecorder{}
+		conn := newTestConnection(t, broker, &Options{Lazy: lazy, Observers: []ConnectionObserver{recorder.observe}})
+		if !lazy && !recorder.received(CONNECTED_EVENT) {
+			t.Fatal("CONNECTED_EVENT of the first connecting is not received")
+		}
+		eventually(t, 2*time.Second, func() bool {
+			return recorder.received(CONNECTED_EVENT) && recorder.received(TOPOLOGY_RESTORED_EVENT)
+		}, "events of the first connecting are not received")
+		_ = conn.Close()
+		if !recorder.received(CLOSED_EVENT) {
+			t.Fatal("CLOSED_EVENT is not received")
+		}
+	}
+}
+
+func TestObserversReceiveFirstReconnectAttempts(t *testing.T) {
+	broker := newFakeBroker(t, nil)
+	recorder := &eventRecorder{}
+	uri := broker.uri("amqp")
+	broker.close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := NewConnectionContext(ctx, "test", &Options{
+		UriAddress:           uri,
+		Backoff:              ConstantBackoff{Delay: 10 * time.Millisecond},
+		ReconnectMaxAttempts: 2,
+		Logger:               NopLogger{},
+		Observers:            []ConnectionObserver{recorder.observe},
+	})
+	if err == nil {
+		t.Fatal("connecting to closed broker succeeded")
+	}
+	if !recorder.received(RECONNECT_ATTEMPT_EVENT) {
+		t.Fatal("RECONNECT_ATTEMPT_EVENT of the first connecting is not received")
+	}
+}
@@ -0,0 +1,55 @@
+package rabbitmq
+
+import (
+	"context"
+)
+
+// TypedEventHandler handle decoded event of type T from specific queue
+type TypedEventHandler[T any] func(ctx context.Context, event T, delivery Delivery) error
+
+// Handle declare new consumer queue bound to exchange with routing keys, the body of each event is decoded
+// to T with the encoder registered for the delivery ContentType before calling handler.
+// Events that cannot be decoded never reach handler, they are reported to Options.ErrorHandler as *DecodeError
+// and rejected. The event is acknowledged when handler returns nil and requeued when it returns error.
+func Handle[T any](conn *Connection, queue, exchange string, handler TypedEventHandler[T], routingKeys ...string) error {
+	return HandleWithOptions(conn, nil, queue, exchange, handler, routingKeys...)
+}
+
+// HandleWithOptions is like Handle but applies opts to the consumer queue
+func HandleWithOptions[T any](conn *Connection, opts *ConsumerOptions, queue, exchange string, handler TypedEventHandler[T], routingKeys ...string) error {
+	if conn == nil {
+		return NIL_CCONECTION_ERROR
+	}
+	if handler == nil {
+		return HANDLER_INVALID_ERROR
+	}
+	return conn.DeclareAckConsumerQueue(func(ctx context.Context, queue string, delivery Delivery) error {
+		event, err := decodeEvent[T](queue, delivery)
+		if err != nil {
+			return err
+		}
+		return handler(ctx, event, delivery)
+	}, opts, queue, exchange, routingKeys...)
+}
+
+// decodeEvent decode the body of delivery to T with the encoder of the delivery content type
+func decodeEvent[T any](queue string, delivery Delivery) (T, error) {
+	var event T
+	enc := encoderForContentType(delivery.ContentType)
+	if enc == nil {
+		return event, &DecodeError{Queue: queue, ContentType: delivery.ContentType, Err: ENCODER_NOT_FOUND_ERROR}
+	}
+	if err := enc.Decode(delivery.Body, &event); err != nil {
+		return event, &DecodeError{Queue: queue, ContentType: delivery.ContentType, Err: err}
+	}
+	return event, nil
+}
+
+// handleError pass err to ErrorHandler or log it
+func (c *Connection) handleError(queue string, delivery Delivery, err error) {
+	if c.ConnOpt.ErrorHandler != nil {
+		c.ConnOpt.ErrorHandler(queue, delivery, err)
+		return
+	}
+	c.logger.Error("error on handling event", "queue", queue, "exchange", delivery.Exchange, "routing_key", delivery.RoutingKey, "delivery_tag", delivery.DeliveryTag, "error", err)
+}
@@ -0,0 +1,103 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// Logger is leveled logger with key value pairs, keysAndValues are alternating keys and values
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// StdLogger write logs with the standard log package, it is the default logger
+type StdLogger struct{}
+
+func (StdLogger) Debug(msg string, keysAndValues ...interface{}) {
+	stdLog("DEBUG", msg, keysAndValues)
+}
+
+func (StdLogger) Info(msg string, keysAndValues ...interface{}) {
+	stdLog("INFO", msg, keysAndValues)
+}
+
+func (StdLogger) Warn(msg string, keysAndValues ...interface{}) {
+	stdLog("WARN", msg, keysAndValues)
+}
+
+func (StdLogger) Error(msg string, keysAndValues ...interface{}) {
+	stdLog("ERROR", msg, keysAndValues)
+}
+
+// stdLog format message and key value pairs in one line
+func stdLog(level, msg string, keysAndValues []interface{}) {
+	var b strings.Builder
+	b.WriteString(level)
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 < len(keysAndValues) {
+			fmt.Fprintf(&b, " %v=%v", keysAndValues[i], keysAndValues[i+1])
+		} else {
+			fmt.Fprintf(&b, " %v", keysAndValues[i])
+		}
+	}
+	log.Print(b.String())
+}
+
+// NopLogger discard all logs
+type NopLogger struct{}
+
+func (NopLogger) Debug(string, ...interface{}) {}
+func (NopLogger) Info(string, ...interface{})  {}
+func (NopLogger) Warn(string, ...interface{})  {}
+func (NopLogger) Error(string, ...interface{}) {}
+
+// serviceLogger add service name to every log
+type serviceLogger struct {
+	logger  Logger
+	service string
+}
+
+func (l serviceLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.logger.Debug(msg, l.with(keysAndValues)...)
+}
+
+func (l serviceLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Info(msg, l.with(keysAndValues)...)
+}
+
+func (l serviceLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.logger.Warn(msg, l.with(keysAndValues)...)
+}
+
+func (l serviceLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.logger.Error(msg, l.with(keysAndValues)...)
+}
+
+func (l serviceLogger) with(keysAndValues []interface{}) []interface{} {
+	return append([]interface{}{"service", l.service}, keysAndValues...)
+}
+
+// redactURI hide password of rabbitMQ address in logs
+func redactURI(addr string) string {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return ""
+	}
+	return u.Redacted()
+}
+
+// redactURIs hide passwords of rabbitMQ addresses in logs
+func redactURIs(addresses []string) []string {
+	redacted := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		redacted = append(redacted, redactURI(addr))
+	}
+	return redacted
+}
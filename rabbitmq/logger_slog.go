@@ -0,0 +1,37 @@
+//go:build go1.21
+
+package rabbitmq
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogLogger adapt slog.Logger to Logger
+type SlogLogger struct {
+	Logger *slog.Logger
+}
+
+// NewSlogLogger create Logger writing to logger, slog.Default is used if logger is nil
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogLogger{Logger: logger}
+}
+
+func (l *SlogLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.Logger.Log(context.Background(), slog.LevelDebug, msg, keysAndValues...)
+}
+
+func (l *SlogLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.Logger.Log(context.Background(), slog.LevelInfo, msg, keysAndValues...)
+}
+
+func (l *SlogLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.Logger.Log(context.Background(), slog.LevelWarn, msg, keysAndValues...)
+}
+
+func (l *SlogLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.Logger.Log(context.Background(), slog.LevelError, msg, keysAndValues...)
+}
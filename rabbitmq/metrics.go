@@ -0,0 +1,73 @@
+package rabbitmq
+
+import "time"
+
+// DeliveryOutcome is how a consumed delivery is settled with rabbitMQ
+type DeliveryOutcome int
+
+const (
+	ACKED_DELIVERY    DeliveryOutcome = iota // ACKED_DELIVERY delivery acknowledged
+	NACKED_DELIVERY                          // NACKED_DELIVERY delivery negatively acknowledged and requeued
+	REJECTED_DELIVERY                        // REJECTED_DELIVERY delivery rejected and dead lettered or dropped
+)
+
+// String return name of delivery outcome
+func (o DeliveryOutcome) String() string {
+	switch o {
+	case ACKED_DELIVERY:
+		return "acked"
+	case NACKED_DELIVERY:
+		return "nacked"
+	case REJECTED_DELIVERY:
+		return "rejected"
+	default:
+		return "unknown"
+	}
+}
+
+// MetricsRecorder record metrics of publishing and consuming, service is ServiceCallerName of the connection.
+// Methods are called synchronously on publishing and consuming paths and must not block
+type MetricsRecorder interface {
+	Published(service, exchange string, duration time.Duration, err error) // Published event with duration of publishing
+	Confirmed(service, exchange string, ack bool)                          // Confirmed event by rabbitMQ in confirm mode
+	Returned(service, exchange string)                                     // Returned mandatory event which is not routed to any queue
+	Consumed(service, queue string)                                        // Consumed delivery from queue
+	Settled(service, queue string, outcome DeliveryOutcome)                // Settled delivery of queue by the library
+	HandlerDuration(service, queue string, duration time.Duration)         // HandlerDuration of handling delivery of queue
+	InFlight(service, queue string, delta int)                             // InFlight handlers of queue changed by delta
+	ConnectionState(service, session string, connected bool)               // ConnectionState of publisher or consumer session changed
+}
+
+// nopMetrics discard metrics, it is used when Options.Metrics is nil
+type nopMetrics struct{}
+
+func (nopMetrics) Published(string, string, time.Duration, error) {}
+func (nopMetrics) Confirmed(string, string, bool)                 {}
+func (nopMetrics) Returned(string, string)                        {}
+func (nopMetrics) Consumed(string, string)                        {}
+func (nopMetrics) Settled(string, string, DeliveryOutcome)        {}
+func (nopMetrics) HandlerDuration(string, string, time.Duration)  {}
+func (nopMetrics) InFlight(string, string, int)                   {}
+func (nopMetrics) ConnectionState(string, string, bool)           {}
+
+// observeConnectionState record connection state of sessions from lifecycle events
+func (c *Connection) observeConnectionState(event ConnectionEvent) {
+	switch event.Kind {
+	case CONNECTED_EVENT:
+		c.metrics.ConnectionState(c.ServiceCallerName, event.Session, true)
+	case DISCONNECTED_EVENT:
+		c.metrics.ConnectionState(c.ServiceCallerName, event.Session, false)
+	case CLOSED_EVENT:
+		for _, s := range c.sessions() {
+			c.metrics.ConnectionState(c.ServiceCallerName, s.name, false)
+		}
+	}
+}
+
+// settle record outcome of delivery settling if err is nil and return err
+func (c *Connection) settle(queue string, outcome DeliveryOutcome, err error) error {
+	if err == nil {
+		c.metrics.Settled(c.ServiceCallerName, queue, outcome)
+	}
+	return err
+}
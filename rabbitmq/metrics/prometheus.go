@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ramoozorg/event-driven/rabbitmq"
+)
+
+const namespace = "rabbitmq"
+
+// PrometheusRecorder is a Prometheus MetricsRecorder implementation for Connection.
+// Metrics are labelled by service which is ServiceCallerName of the connection, exchange and queue.
+type PrometheusRecorder struct {
+	published       *prometheus.CounterVec
+	publishErrors   *prometheus.CounterVec
+	confirmed       *prometheus.CounterVec
+	returned        *prometheus.CounterVec
+	consumed        *prometheus.CounterVec
+	settled         *prometheus.CounterVec
+	publishDuration *prometheus.HistogramVec
+	handlerDuration *prometheus.HistogramVec
+	inFlight        *prometheus.GaugeVec
+	connected       *prometheus.GaugeVec
+}
+
+var _ rabbitmq.MetricsRecorder = (*PrometheusRecorder)(nil)
+
+// NewPrometheusRecorder create PrometheusRecorder and register its metrics on registerer,
+// prometheus.DefaultRegisterer is used if registerer is nil
+func NewPrometheusRecorder(registerer prometheus.Registerer) (*PrometheusRecorder, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	r := &PrometheusRecorder{
+		published: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "published_total",
+			Help:      "Number of events published to rabbitMQ.",
+		}, []string{"service", "exchange"}),
+		publishErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "publish_errors_total",
+			Help:      "Number of events failed to publish to rabbitMQ.",
+		}, []string{"service", "exchange"}),
+		confirmed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "confirmed_total",
+			Help:      "Number of published events confirmed by rabbitMQ, result is ack or nack.",
+		}, []string{"service", "exchange", "result"}),
+		returned: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "returned_total",
+			Help:      "Number of mandatory events returned by rabbitMQ.",
+		}, []string{"service", "exchange"}),
+		consumed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "consumed_total",
+			Help:      "Number of deliveries consumed from rabbitMQ.",
+		}, []string{"service", "queue"}),
+		settled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "settled_total",
+			Help:      "Number of deliveries settled, outcome is acked, nacked or rejected.",
+		}, []string{"service", "queue", "outcome"}),
+		publishDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "publish_duration_seconds",
+			Help:      "Duration of publishing events to rabbitMQ.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"service", "exchange"}),
+		handlerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "handler_duration_seconds",
+			Help:      "Duration of handling deliveries.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"service", "queue"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "in_flight_handlers",
+			Help:      "Number of deliveries which are being handled.",
+		}, []string{"service", "queue"}),
+		connected: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "connected",
+			Help:      "Connection state of publisher and consumer sessions, 1 if connected.",
+		}, []string{"service", "session"}),
+	}
+	collectors := []prometheus.Collector{r.published, r.publishErrors, r.confirmed, r.returned, r.consumed,
+		r.settled, r.publishDuration, r.handlerDuration, r.inFlight, r.connected}
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Published count published event and observe its publishing duration, failed publishing is counted as publish error
+func (r *PrometheusRecorder) Published(service, exchange string, duration time.Duration, err error) {
+	if err != nil {
+		r.publishErrors.WithLabelValues(service, exchange).Inc()
+		return
+	}
+	r.published.WithLabelValues(service, exchange).Inc()
+	r.publishDuration.WithLabelValues(service, exchange).Observe(duration.Seconds())
+}
+
+// Confirmed count confirmation of published event by its ack or nack result
+func (r *PrometheusRecorder) Confirmed(service, exchange string, ack bool) {
+	result := "ack"
+	if !ack {
+		result = "nack"
+	}
+	r.confirmed.WithLabelValues(service, exchange, result).Inc()
+}
+
+// Returned count mandatory event returned by rabbitMQ
+func (r *PrometheusRecorder) Returned(service, exchange string) {
+	r.returned.WithLabelValues(service, exchange).Inc()
+}
+
+// Consumed count delivery consumed from queue
+func (r *PrometheusRecorder) Consumed(service, queue string) {
+	r.consumed.WithLabelValues(service, queue).Inc()
+}
+
+// Settled count delivery of queue settled by its outcome
+func (r *PrometheusRecorder) Settled(service, queue string, outcome rabbitmq.DeliveryOutcome) {
+	r.settled.WithLabelValues(service, queue, outcome.String()).Inc()
+}
+
+// HandlerDuration observe duration of handling delivery of queue
+func (r *PrometheusRecorder) HandlerDuration(service, queue string, duration time.Duration) {
+	r.handlerDuration.WithLabelValues(service, queue).Observe(duration.Seconds())
+}
+
+// InFlight add delta to number of handlers of queue which are handling deliveries
+func (r *PrometheusRecorder) InFlight(service, queue string, delta int) {
+	r.inFlight.WithLabelValues(service, queue).Add(float64(delta))
+}
+
+// ConnectionState set connection state gauge of publisher or consumer session, 1 if connected
+func (r *PrometheusRecorder) ConnectionState(service, session string, connected bool) {
+	value := 0.0
+	if connected {
+		value = 1
+	}
+	r.connected.WithLabelValues(service, session).Set(value)
+}
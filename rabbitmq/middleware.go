@@ -0,0 +1,78 @@
+package rabbitmq
+
+import "context"
+
+// PublishFunc publishes serialized event to exchange with routingKey
+type PublishFunc func(ctx context.Context, exchange, routingKey string, body []byte, publishOptions PublishingOptions) error
+
+// PublishMiddleware wraps publishing, it may change the event and its options before calling next or stop it by returning error.
+// If it returns nil without calling next, PublishAsync and PublishWithConfirm report the event as acknowledged
+type PublishMiddleware func(next PublishFunc) PublishFunc
+
+// ConsumeMiddleware wraps handling of deliveries. Deliveries of queues declared by DeclareAckConsumerQueue are settled
+// by calling next, so a middleware which does not call next for them must acknowledge or reject the delivery itself
+type ConsumeMiddleware func(next EventHandler) EventHandler
+
+// UsePublish register middlewares for publishing to every exchange. Global middlewares wrap middlewares of exchanges
+// and middlewares are called in order of registering, so the first registered global middleware is called first
+func (c *Connection) UsePublish(middlewares ...PublishMiddleware) {
+	c.middlewareMu.Lock()
+	defer c.middlewareMu.Unlock()
+	c.publishMiddlewares = append(c.publishMiddlewares, middlewares...)
+}
+
+// UsePublishFor register middlewares for publishing to exchange, they are called after global middlewares
+func (c *Connection) UsePublishFor(exchange string, middlewares ...PublishMiddleware) {
+	c.middlewareMu.Lock()
+	defer c.middlewareMu.Unlock()
+	if c.exchangePublishMiddlewares == nil {
+		c.exchangePublishMiddlewares = make(map[string][]PublishMiddleware)
+	}
+	c.exchangePublishMiddlewares[exchange] = append(c.exchangePublishMiddlewares[exchange], middlewares...)
+}
+
+// UseConsume register middlewares for handling deliveries of every queue. Global middlewares wrap middlewares of queues
+// and middlewares are called in order of registering, so the first registered global middleware is called first
+func (c *Connection) UseConsume(middlewares ...ConsumeMiddleware) {
+	c.middlewareMu.Lock()
+	defer c.middlewareMu.Unlock()
+	c.consumeMiddlewares = append(c.consumeMiddlewares, middlewares...)
+}
+
+// UseConsumeFor register middlewares for handling deliveries of queue, they are called after global middlewares
+func (c *Connection) UseConsumeFor(queue string, middlewares ...ConsumeMiddleware) {
+	c.middlewareMu.Lock()
+	defer c.middlewareMu.Unlock()
+	if c.queueConsumeMiddlewares == nil {
+		c.queueConsumeMiddlewares = make(map[string][]ConsumeMiddleware)
+	}
+	c.queueConsumeMiddlewares[queue] = append(c.queueConsumeMiddlewares[queue], middlewares...)
+}
+
+// publishChain wraps publish with global middlewares and middlewares of exchange
+func (c *Connection) publishChain(exchange string, publish PublishFunc) PublishFunc {
+	c.middlewareMu.RLock()
+	global, forExchange := c.publishMiddlewares, c.exchangePublishMiddlewares[exchange]
+	c.middlewareMu.RUnlock()
+	for i := len(forExchange) - 1; i >= 0; i-- {
+		publish = forExchange[i](publish)
+	}
+	for i := len(global) - 1; i >= 0; i-- {
+		publish = global[i](publish)
+	}
+	return publish
+}
+
+// consumeChain wraps handler with global middlewares and middlewares of queue
+func (c *Connection) consumeChain(queue string, handler EventHandler) EventHandler {
+	c.middlewareMu.RLock()
+	global, forQueue := c.consumeMiddlewares, c.queueConsumeMiddlewares[queue]
+	c.middlewareMu.RUnlock()
+	for i := len(forQueue) - 1; i >= 0; i-- {
+		handler = forQueue[i](handler)
+	}
+	for i := len(global) - 1; i >= 0; i-- {
+		handler = global[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,252 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordPublish return publish middleware which record name before calling next
+func recordPublish(calls *[]string, name string) PublishMiddleware {
+	return func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, exchange, routingKey string, body []byte, publishOptions PublishingOptions) error {
+			*calls = append(*calls, name)
+			return next(ctx, exchange, routingKey, body, publishOptions)
+		}
+	}
+}
+
+// recordConsume return consume middleware which record name before calling next
+func recordConsume(calls *[]string, name string) ConsumeMiddleware {
+	return func(next EventHandler) EventHandler {
+		return func(queue string, delivery Delivery) {
+			*calls = append(*calls, name)
+			next(queue, delivery)
+		}
+	}
+}
+
+func TestPublishChainOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(c *Connection, calls *[]string)
+		want     []string
+	}{
+		{
+			name:     "without middlewares",
+			register: func(c *Connection, calls *[]string) {},
+			want:     []string{"publish"},
+		},
+		{
+			name: "global in order of registering",
+			register: func(c *Connection, calls *[]string) {
+				c.UsePublish(recordPublish(calls, "g1"), recordPublish(calls, "g2"))
+				c.UsePublish(recordPublish(calls, "g3"))
+			},
+			want: []string{"g1", "g2", "g3", "publish"},
+		},
+		{
+			name: "exchange after global",
+			register: func(c *Connection, calls *[]string) {
+				c.UsePublishFor("ex", recordPublish(calls, "e1"))
+				c.UsePublish(recordPublish(calls, "g1"))
+				c.UsePublishFor("ex", recordPublish(calls, "e2"))
+				c.UsePublish(recordPublish(calls, "g2"))
+			},
+			want: []string{"g1", "g2", "e1", "e2", "publish"},
+		},
+		{
+			name: "other exchange",
+			register: func(c *Connection, calls *[]string) {
+				c.UsePublishFor("other", recordPublish(calls, "o1"))
+				c.UsePublish(recordPublish(calls, "g1"))
+			},
+			want: []string{"g1", "publish"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Connection{}
+			var calls []string
+			tt.register(c, &calls)
+			publish := c.publishChain("ex", func(ctx context.Context, exchange, routingKey string, body []byte, publishOptions PublishingOptions) error {
+				calls = append(calls, "publish")
+				return nil
+			})
+			if err := publish(context.Background(), "ex", "rk", nil, PublishingOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(calls, tt.want) {
+				t.Fatalf("called %v, want %v", calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsumeChainOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(c *Connection, calls *[]string)
+		want     []string
+	}{
+		{
+			name:     "without middlewares",
+			register: func(c *Connection, calls *[]string) {},
+			want:     []string{"handler"},
+		},
+		{
+			name: "global in order of registering",
+			register: func(c *Connection, calls *[]string) {
+				c.UseConsume(recordConsume(calls, "g1"), recordConsume(calls, "g2"))
+				c.UseConsume(recordConsume(calls, "g3"))
+			},
+			want: []string{"g1", "g2", "g3", "handler"},
+		},
+		{
+			name: "queue after global",
+			register: func(c *Connection, calls *[]string) {
+				c.UseConsumeFor("q", recordConsume(calls, "q1"))
+				c.UseConsume(recordConsume(calls, "g1"))
+				c.UseConsumeFor("q", recordConsume(calls, "q2"))
+				c.UseConsume(recordConsume(calls, "g2"))
+			},
+			want: []string{"g1", "g2", "q1", "q2", "handler"},
+		},
+		{
+			name: "other queue",
+			register: func(c *Connection, calls *[]string) {
+				c.UseConsumeFor("other", recordConsume(calls, "o1"))
+				c.UseConsume(recordConsume(calls, "g1"))
+			},
+			want: []string{"g1", "handler"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Connection{}
+			var calls []string
+			tt.register(c, &calls)
+			handler := c.consumeChain("q", func(queue string, delivery Delivery) {
+				calls = append(calls, "handler")
+			})
+			handler("q", Delivery{})
+			if !reflect.DeepEqual(calls, tt.want) {
+				t.Fatalf("called %v, want %v", calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishMiddlewareShortCircuit(t *testing.T) {
+	broker := newFakeBroker(t, nil)
+	conn := newTestConnection(t, broker, &Options{ConfirmMode: true})
+	if err := conn.ExchangeDeclare("ex", DIRECT); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.DeclareConsumerQueue(func(string, Delivery) {}, "q", "ex", "drop", "fail"); err != nil {
+		t.Fatal(err)
+	}
+	middlewareErr := errors.New("stopped")
+	conn.UsePublishFor("ex", func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, exchange, routingKey string, body []byte, publishOptions PublishingOptions) error {
+			switch routingKey {
+			case "drop":
+				return nil
+			case "fail":
+				return middlewareErr
+			}
+			return next(ctx, exchange, routingKey, body, publishOptions)
+		}
+	})
+
+	confirm, err := conn.PublishAsync("ex", "drop", testEvent{Name: "a"}, PublishingOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-confirm:
+		if !c.Ack || c.Err != nil {
+			t.Fatalf("dropped event is confirmed with %+v, want ack", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("dropped event is not confirmed")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := conn.PublishWithConfirm(ctx, "ex", "drop", testEvent{Name: "a"}, PublishingOptions{}); err != nil {
+		t.Fatalf("PublishWithConfirm of dropped event returned %v, want nil", err)
+	}
+	if err := conn.PublishWithConfirm(ctx, "ex", "fail", testEvent{Name: "a"}, PublishingOptions{}); !errors.Is(err, middlewareErr) {
+		t.Fatalf("PublishWithConfirm returned %v, want middleware error", err)
+	}
+	if n := broker.messageCount("q"); n != 0 {
+		t.Fatalf("%d events are published, want 0", n)
+	}
+}
+
+func TestConsumeMiddlewareShortCircuitWithManualAck(t *testing.T) {
+	tests := []struct {
+		name   string
+		settle func(delivery Delivery)
+		want   int
+	}{
+		{
+			name:   "rejected by middleware",
+			settle: func(delivery Delivery) { _ = delivery.Reject(false) },
+			want:   0,
+		},
+		{
+			name:   "not settled",
+			settle: func(Delivery) {},
+			want:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := newFakeBroker(t, nil)
+			conn := newTestConnection(t, broker, nil)
+			if err := conn.ExchangeDeclare("ex", DIRECT); err != nil {
+				t.Fatal(err)
+			}
+			handled := make(chan struct{}, 1)
+			err := conn.DeclareAckConsumerQueue(func(ctx context.Context, queue string, delivery Delivery) error {
+				handled <- struct{}{}
+				return nil
+			}, nil, "q", "ex", "rk")
+			if err != nil {
+				t.Fatal(err)
+			}
+			stopped := make(chan struct{}, 1)
+			conn.UseConsumeFor("q", func(next EventHandler) EventHandler {
+				return func(queue string, delivery Delivery) {
+					tt.settle(delivery)
+					stopped <- struct{}{}
+				}
+			})
+			if err := conn.Consume(); err != nil {
+				t.Fatal(err)
+			}
+			broker.enqueue("q", &fakeMessage{exchange: "ex", routingKey: "rk", body: []byte("event")})
+			select {
+			case <-stopped:
+			case <-time.After(2 * time.Second):
+				t.Fatal("event is not passed to middleware")
+			}
+			// unacknowledged events are requeued when the connection is closed
+			if err := conn.Close(); err != nil {
+				t.Fatal(err)
+			}
+			eventually(t, 2*time.Second, func() bool { return broker.openConnections() == 0 }, "connection is not closed")
+			select {
+			case <-handled:
+				t.Fatal("handler is called by middleware which did not call next")
+			default:
+			}
+			if n := broker.messageCount("q"); n != tt.want {
+				t.Fatalf("%d events are left in queue, want %d", n, tt.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package rabbitmq
 
 import (
+	"context"
+	"crypto/tls"
 	"github.com/streadway/amqp"
-	"os"
+	"sync"
 	"time"
 )
 
@@ -13,23 +15,54 @@ type (
 	Headers      amqp.Table                            // Headers table for set event header when publishing
 )
 
-// Connection is the structure of amqp event connection
+// ErrorHandler handle errors occurred while processing event from specific queue
+type ErrorHandler func(queue string, delivery Delivery, err error)
+
+// BlockedHandler is called when rabbitMQ blocks or unblocks a connection, publishing is false for the consuming connection
+type BlockedHandler func(publishing bool, blocked bool, reason string)
+
+// Connection is the structure of amqp event connection. It is safe for concurrent use by multiple goroutines,
+// declaring, publishing, consuming and closing can be called concurrently and while reconnecting.
+// Declarations made while disconnected are declared on rabbitMQ after connecting.
 type Connection struct {
-	conn              *amqp.Connection // conn rabbitMQ connection Object
-	channel           *amqp.Channel    // channel amqp channel Object
-	done              chan os.Signal
-	notifyClose       chan *amqp.Error
-	isConnected       bool
+	publisherSession  *session       // publisherSession connection used for publishing
+	consumerSession   *session       // consumerSession connection used for consuming and declaring, same as publisherSession if connections are not separated
+	publishers        *publisherPool // publishers channels used for publishing
+	nodes             *nodeSelector  // nodes of rabbitMQ cluster used for connecting
+	tlsConfig         *tls.Config    // tlsConfig used for dialing nodes, nil if TLS is not enabled
+	logger            Logger         // logger of Options.Logger with service name
+	metrics           MetricsRecorder
+	tracer            Tracer
 	alive             bool
 	exchanges         []string                // exchanges list
 	queues            map[string]EventHandler // queue and event handler
 	ServiceCallerName string
 	ConnOpt           *Options
+
+	mu                   sync.RWMutex         // mu guards publishers, declared exchanges and queues and consumers
+	stateMu              sync.Mutex           // stateMu guards alive and err
+	err                  error                // err is the reason of failed state
+	observersMu          sync.RWMutex         // observersMu guards observers
+	observers            []ConnectionObserver // observers of connection lifecycle events
+	consumers            map[string]*consumer // consumers started by Consume
+	consumersWg          sync.WaitGroup       // consumersWg wait for supervisors of consumers
+	ctx                  context.Context      // ctx of the connection passed to handlers, cancelled on Close
+	cancel               context.CancelFunc
+	consumerQueues       map[string]*consumerQueue // consumerQueues options of queues declared with consumer options
+	exchangeDeclarations []exchangeDeclaration     // exchangeDeclarations record of declared exchanges, replayed after reconnect
+	queueDeclarations    []queueDeclaration        // queueDeclarations record of declared queues and bindings, replayed after reconnect
+
+	middlewareMu               sync.RWMutex                   // middlewareMu guards middlewares
+	publishMiddlewares         []PublishMiddleware            // publishMiddlewares of every exchange
+	exchangePublishMiddlewares map[string][]PublishMiddleware // exchangePublishMiddlewares by exchange
+	consumeMiddlewares         []ConsumeMiddleware            // consumeMiddlewares of every queue
+	queueConsumeMiddlewares    map[string][]ConsumeMiddleware // queueConsumeMiddlewares by queue
 }
 
 // PublishingOptions options for event
 type PublishingOptions struct {
-	Headers Headers // rabbitMQ event headers
+	Headers   Headers // rabbitMQ event headers
+	Mandatory bool    // rabbitMQ returns the event if it is not routed to any queue, returned events are recorded by Options.Metrics
 	// Properties
 	ContentType     string    // MIME content type
 	ContentEncoding string    // MIME content encoding
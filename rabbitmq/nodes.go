@@ -0,0 +1,68 @@
+package rabbitmq
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// NodeSelection is the strategy for choosing rabbitMQ cluster node on connecting
+type NodeSelection int
+
+const (
+	ROUND_ROBIN_NODE_SELECTION NodeSelection = iota // ROUND_ROBIN_NODE_SELECTION start every connecting from the node after the last tried one
+	RANDOM_NODE_SELECTION                           // RANDOM_NODE_SELECTION try nodes in random order
+	PRIORITY_NODE_SELECTION                         // PRIORITY_NODE_SELECTION try nodes in order of UriAddresses, the first node is preferred
+)
+
+// String return name of node selection
+func (n NodeSelection) String() string {
+	switch n {
+	case ROUND_ROBIN_NODE_SELECTION:
+		return "round-robin"
+	case RANDOM_NODE_SELECTION:
+		return "random"
+	case PRIORITY_NODE_SELECTION:
+		return "priority"
+	default:
+		return "unknown"
+	}
+}
+
+// nodeSelector order rabbitMQ nodes for connecting
+type nodeSelector struct {
+	mu        sync.Mutex
+	addresses []string
+	selection NodeSelection
+	next      int
+	rand      *rand.Rand
+}
+
+// newNodeSelector create node selector of addresses
+func newNodeSelector(addresses []string, selection NodeSelection) *nodeSelector {
+	return &nodeSelector{
+		addresses: addresses,
+		selection: selection,
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// order return addresses in order they should be tried for connecting
+func (n *nodeSelector) order() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	addresses := make([]string, 0, len(n.addresses))
+	switch n.selection {
+	case RANDOM_NODE_SELECTION:
+		for _, i := range n.rand.Perm(len(n.addresses)) {
+			addresses = append(addresses, n.addresses[i])
+		}
+	case PRIORITY_NODE_SELECTION:
+		addresses = append(addresses, n.addresses...)
+	default:
+		addresses = append(addresses, n.addresses[n.next:]...)
+		addresses = append(addresses, n.addresses[:n.next]...)
+		n.next = (n.next + 1) % len(n.addresses)
+	}
+	return addresses
+}
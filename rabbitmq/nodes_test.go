@@ -0,0 +1,66 @@
+package rabbitmq
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestNodeSelectorOrder(t *testing.T) {
+	addresses := []string{"a", "b", "c"}
+	tests := []struct {
+		name      string
+		selection NodeSelection
+		want      [][]string
+	}{
+		{
+			name:      "round robin",
+			selection: ROUND_ROBIN_NODE_SELECTION,
+			want: [][]string{
+				{"a", "b", "c"},
+				{"b", "c", "a"},
+				{"c", "a", "b"},
+				{"a", "b", "c"},
+			},
+		},
+		{
+			name:      "priority",
+			selection: PRIORITY_NODE_SELECTION,
+			want: [][]string{
+				{"a", "b", "c"},
+				{"a", "b", "c"},
+				{"a", "b", "c"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newNodeSelector(addresses, tt.selection)
+			for i, want := range tt.want {
+				if got := n.order(); !reflect.DeepEqual(got, want) {
+					t.Fatalf("order %d is %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNodeSelectorRandomOrder(t *testing.T) {
+	addresses := []string{"a", "b", "c"}
+	n := newNodeSelector(addresses, RANDOM_NODE_SELECTION)
+	firsts := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		got := n.order()
+		firsts[got[0]] = true
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, addresses) {
+			t.Fatalf("random order %v is not a permutation of %v", got, addresses)
+		}
+	}
+	if len(firsts) != len(addresses) {
+		t.Fatalf("only %v are tried first in 100 orders", firsts)
+	}
+	if !reflect.DeepEqual(addresses, []string{"a", "b", "c"}) {
+		t.Fatalf("addresses of selector are changed to %v", addresses)
+	}
+}
@@ -0,0 +1,220 @@
+package rabbitmq
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	defaultPublisherPoolSize = 1
+)
+
+// publisherChannel is a channel of the publisher pool, publishes on it are serialized and in confirm mode
+// confirmations are correlated with published events by delivery tag. When rabbitMQ closes the channel
+// but not the connection, e.g. publishing to a deleted exchange, the channel is opened again on conn.
+// Confirmations are resolved under pendingMu only, because streadway blocks publishing on the channel
+// while its reader delivers confirmations, so waiting for mu there deadlocks the connection
+type publisherChannel struct {
+	mu      sync.Mutex // mu serializes publishing and opening the channel
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	confirm bool
+	nextTag uint64
+	closed  bool
+	service string
+	metrics MetricsRecorder
+
+	pendingMu sync.Mutex // pendingMu guards pending and changed
+	pending   map[uint64]pendingConfirm
+	changed   chan struct{} // changed closed and replaced when pending events confirmed
+}
+
+// pendingConfirm is a waiter of confirmation of event published to exchange
+type pendingConfirm struct {
+	confirm  chan Confirmation
+	exchange string
+}
+
+// publisherPool channels of a connection used for publishing, separated from consuming channels
+type publisherPool struct {
+	channels []*publisherChannel
+	next     uint32
+}
+
+// newPublisherPool open size channels on conn for publishing, channels are in confirm mode if confirm is true
+func newPublisherPool(conn *amqp.Connection, size int, confirm bool, service string, metrics MetricsRecorder) (*publisherPool, error) {
+	pool := &publisherPool{}
+	for i := 0; i < size; i++ {
+		pc, err := newPublisherChannel(conn, confirm, service, metrics)
+		if err != nil {
+			return nil, err
+		}
+		pool.channels = append(pool.channels, pc)
+	}
+	return pool, nil
+}
+
+// get return the next channel of the pool in round-robin order
+func (p *publisherPool) get() *publisherChannel {
+	n := atomic.AddUint32(&p.next, 1)
+	return p.channels[n%uint32(len(p.channels))]
+}
+
+// wait block until all pending events of the pool confirmed or ctx done, returns number of pending events
+func (p *publisherPool) wait(ctx context.Context) int {
+	pending := 0
+	for _, pc := range p.channels {
+		pending += pc.wait(ctx)
+	}
+	return pending
+}
+
+// newPublisherChannel open channel on conn for publishing
+func newPublisherChannel(conn *amqp.Connection, confirm bool, service string, metrics MetricsRecorder) (*publisherChannel, error) {
+	pc := &publisherChannel{
+		conn:    conn,
+		confirm: confirm,
+		pending: make(map[uint64]pendingConfirm),
+		changed: make(chan struct{}),
+		service: service,
+		metrics: metrics,
+	}
+	if err := pc.open(); err != nil {
+		return nil, err
+	}
+	return pc, nil
+}
+
+// open open a new channel, put it into confirm mode if confirm is true and listen to its confirmations,
+// returns and closing. pc.mu must be held if pc is in use
+func (pc *publisherChannel) open() error {
+	channel, err := pc.conn.Channel()
+	if err != nil {
+		return err
+	}
+	go pc.listenReturn(channel.NotifyReturn(make(chan amqp.Return, 1)))
+	var confirms chan amqp.Confirmation
+	if pc.confirm {
+		if err := channel.Confirm(false); err != nil {
+			_ = channel.Close()
+			return err
+		}
+		confirms = channel.NotifyPublish(make(chan amqp.Confirmation, 128))
+	}
+	notifyClose := channel.NotifyClose(make(chan *amqp.Error, 1))
+	pc.channel = channel
+	pc.nextTag = 0
+	pc.closed = false
+	go pc.listen(channel, confirms, notifyClose)
+	return nil
+}
+
+// publish publishes event on channel, in confirm mode a waiter is registered for its delivery tag before
+// publishing, so the confirmation is resolved even if it arrives before publish returns.
+// A channel closed by rabbitMQ is opened again while the connection is open
+func (pc *publisherChannel) publish(exchange, routingKey string, body []byte, publishOptions PublishingOptions) (<-chan Confirmation, error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.closed {
+		if pc.conn.IsClosed() {
+			return nil, CONNECTION_CLOSED_ERROR
+		}
+		if err := pc.open(); err != nil {
+			return nil, err
+		}
+	}
+	if !pc.confirm {
+		return nil, publish(pc.channel, exchange, routingKey, body, publishOptions)
+	}
+	tag := pc.nextTag + 1
+	confirm := make(chan Confirmation, 1)
+	pc.pendingMu.Lock()
+	pc.pending[tag] = pendingConfirm{confirm: confirm, exchange: exchange}
+	pc.pendingMu.Unlock()
+	if err := publish(pc.channel, exchange, routingKey, body, publishOptions); err != nil {
+		// the delivery tag is not used by streadway when publishing failed
+		pc.pendingMu.Lock()
+		delete(pc.pending, tag)
+		pc.notifyChanged()
+		pc.pendingMu.Unlock()
+		return nil, err
+	}
+	pc.nextTag = tag
+	return confirm, nil
+}
+
+// listen resolve waiters by received confirmations in confirm mode until channel closed, then it fails pending waiters
+// with the reason of closing and opens the channel again if the connection is open
+func (pc *publisherChannel) listen(channel *amqp.Channel, confirms chan amqp.Confirmation, notifyClose chan *amqp.Error) {
+	if confirms != nil {
+		for c := range confirms {
+			pc.pendingMu.Lock()
+			pending, ok := pc.pending[c.DeliveryTag]
+			if ok {
+				pending.confirm <- Confirmation{DeliveryTag: c.DeliveryTag, Ack: c.Ack}
+				delete(pc.pending, c.DeliveryTag)
+				pc.notifyChanged()
+			}
+			pc.pendingMu.Unlock()
+			if ok {
+				pc.metrics.Confirmed(pc.service, pending.exchange, c.Ack)
+			}
+		}
+	}
+	var closeErr error = CONNECTION_CLOSED_ERROR
+	if reason := <-notifyClose; reason != nil {
+		closeErr = reason
+	}
+	// confirmations of channel are closed, so publishing on it does not block and mu can be waited for
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.channel != channel {
+		// channel is already opened again by publish
+		return
+	}
+	pc.closed = true
+	pc.pendingMu.Lock()
+	for tag, pending := range pc.pending {
+		pending.confirm <- Confirmation{DeliveryTag: tag, Err: closeErr}
+		delete(pc.pending, tag)
+	}
+	pc.notifyChanged()
+	pc.pendingMu.Unlock()
+	if !pc.conn.IsClosed() {
+		// publish tries opening again if it fails
+		_ = pc.open()
+	}
+}
+
+// listenReturn record returned mandatory events until channel closed
+func (pc *publisherChannel) listenReturn(returns chan amqp.Return) {
+	for r := range returns {
+		pc.metrics.Returned(pc.service, r.Exchange)
+	}
+}
+
+// notifyChanged wake up waiters of pending confirmations, pc.pendingMu must be held
+func (pc *publisherChannel) notifyChanged() {
+	close(pc.changed)
+	pc.changed = make(chan struct{})
+}
+
+// wait block until all pending events confirmed or ctx done, returns number of pending events
+func (pc *publisherChannel) wait(ctx context.Context) int {
+	for {
+		pc.pendingMu.Lock()
+		pending, changed := len(pc.pending), pc.changed
+		pc.pendingMu.Unlock()
+		if pending == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return pending
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// newTestConnection connect to broker with options which reconnect fast and do not log
+func newTestConnection(t *testing.T, broker *fakeBroker, opts *Options) *Connection {
+	t.Helper()
+	if opts == nil {
+		opts = &Options{}
+	}
+	if len(opts.UriAddress) == 0 && len(opts.UriAddresses) == 0 {
+		opts.UriAddress = broker.uri("amqp")
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = ConstantBackoff{Delay: 20 * time.Millisecond}
+	}
+	if opts.Logger == nil {
+		opts.Logger = NopLogger{}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := NewConnectionContext(ctx, "test", opts)
+	if err != nil {
+		t.Fatalf("cannot connect to fake broker: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// eventually fail the test if condition is not true before timeout
+func eventually(t *testing.T, timeout time.Duration, condition func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPublisherChannelReopenedAfterChannelClosed(t *testing.T) {
+	for _, confirm := range []bool{false, true} {
+		broker := newFakeBroker(t, nil)
+		conn := newTestConnection(t, broker, &Options{ConfirmMode: confirm})
+		if err := conn.ExchangeDeclare("ex", DIRECT); err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.DeclarePublisherQueue("q", "ex", "rk"); err != nil {
+			t.Fatal(err)
+		}
+		broker.deleteExchange("ex")
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := conn.PublishWithConfirm(ctx, "ex", "rk", map[string]string{"a": "b"}, PublishingOptions{})
+		cancel()
+		var amqpErr *amqp.Error
+		if confirm && (!errors.As(err, &amqpErr) || amqpErr.Code != amqp.NotFound) {
+			t.Fatalf("publishing to deleted exchange returned %v, want channel closed with not found", err)
+		}
+		if !confirm {
+			_ = conn.Publish("ex", "rk", map[string]string{"a": "b"}, PublishingOptions{})
+		}
+		broker.mu.Lock()
+		broker.exchanges["ex"] = "direct"
+		broker.bindings = append(broker.bindings, fakeBinding{queue: "q", exchange: "ex", key: "rk"})
+		broker.mu.Unlock()
+		eventually(t, 2*time.Second, func() bool {
+			return conn.Publish("ex", "rk", map[string]string{"a": "b"}, PublishingOptions{}) == nil && broker.messageCount("q") > 0
+		}, "publisher channel is not opened again after rabbitMQ closed it")
+		if !conn.IsPublisherConnected() {
+			t.Fatal("publisher connection closed by channel error")
+		}
+	}
+}
+
+// slowConfirmMetrics record confirmations slowly like a metrics backend under load,
+// confirming is closed when the first confirmation is recorded
+type slowConfirmMetrics struct {
+	nopMetrics
+	once       sync.Once
+	confirming chan struct{}
+}
+
+func (m *slowConfirmMetrics) Confirmed(string, string, bool) {
+	m.once.Do(func() { close(m.confirming) })
+	time.Sleep(50 * time.Microsecond)
+}
+
+func TestPublisherChannelMultipleAcks(t *testing.T) {
+	broker := newFakeBroker(t, nil)
+	broker.mu.Lock()
+	broker.confirmBatch = 200
+	broker.mu.Unlock()
+	metrics := &slowConfirmMetrics{confirming: make(chan struct{})}
+	conn := newTestConnection(t, broker, &Options{ConfirmMode: true, Metrics: metrics})
+	if err := conn.ExchangeDeclare("ex", DIRECT); err != nil {
+		t.Fatal(err)
+	}
+	// the first event of every publisher fills the first batch, the others are published
+	// while the multiple acknowledgement of the batch is delivered
+	const publishers, events = 200, 5
+	errs := make(chan error, publishers*events)
+	var wg sync.WaitGroup
+	for i := 0; i < publishers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			confirms := make([]<-chan Confirmation, 0, events)
+			for j := 0; j < events; j++ {
+				if j == 1 {
+					<-metrics.confirming
+				}
+				confirm, err := conn.PublishAsync("ex", "rk", map[string]string{"a": "b"}, PublishingOptions{})
+				if err != nil {
+					errs <- err
+					return
+				}
+				confirms = append(confirms, confirm)
+			}
+			for _, confirm := range confirms {
+				select {
+				case c := <-confirm:
+					if c.Err != nil || !c.Ack {
+						errs <- fmt.Errorf("event is not confirmed: %+v", c)
+						return
+					}
+				case <-time.After(5 * time.Second):
+					errs <- errors.New("confirmation is not received")
+					return
+				}
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("publishing with multiple acknowledgements deadlocked")
+	}
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
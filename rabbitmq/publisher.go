@@ -1,6 +1,7 @@
 package rabbitmq
 
 import (
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/streadway/amqp"
@@ -8,35 +9,87 @@ import (
 	"time"
 )
 
-//Publish publishes a request to the amqp queue
+// Publish publishes a request to the amqp queue, the body is encoded with bson.
+// It returns BROKER_BLOCKED_ERROR without waiting if rabbitMQ blocked the publishing connection
 func (c *Connection) Publish(exchange, routingKey string, body interface{}, publishOptions PublishingOptions) error {
-	if !checkElementInSlice(c.exchanges, exchange) {
-		return EXHCNAGE_NOT_FOUND_ERROR
-	}
+	return c.PublishWithContext(context.Background(), exchange, routingKey, body, publishOptions)
+}
+
+// PublishWithContext is like Publish, ctx is the parent of the publishing span if Options.Tracer is set and
+// bounds waiting for reconnecting when the publishing connection is closed
+func (c *Connection) PublishWithContext(ctx context.Context, exchange, routingKey string, body interface{}, publishOptions PublishingOptions) error {
 	// serialized event to bson
 	b, err := bson.Marshal(body)
 	if err != nil {
 		return err
 	}
+	if len(publishOptions.ContentType) == 0 {
+		publishOptions.ContentType = BSON_CONTENT_TYPE
+	}
+	return c.publishBytes(ctx, exchange, routingKey, b, publishOptions)
+}
+
+// publishBytes publishes serialized event and wait for reconnecting until ctx done if connection closed
+func (c *Connection) publishBytes(ctx context.Context, exchange, routingKey string, b []byte, publishOptions PublishingOptions) error {
+	if !c.hasExchange(exchange) {
+		return EXHCNAGE_NOT_FOUND_ERROR
+	}
 	// try to publish event
-	if err := publish(c, exchange, routingKey, b, publishOptions); err != nil {
+	if _, err := c.publishWithMiddlewares(ctx, exchange, routingKey, b, publishOptions); err != nil {
 		if errors.Is(err, CONNECTION_CLOSED_ERROR) {
-			for {
-				if c.isConnected {
-					break
-				}
-				time.Sleep(1 * time.Second)
-			}
+			c.waitPublisherConnected(ctx)
 		}
 		return err
 	}
 	return nil
 }
 
-func publish(conn *Connection, exchange, routingKey string, body []byte, publishingOptions PublishingOptions) error {
-	if !conn.isConnected {
-		return CONNECTION_CLOSED_ERROR
+// waitPublisherConnected wait until publishing connection connected, ctx done or the connection closed by Close
+func (c *Connection) waitPublisherConnected(ctx context.Context) {
+	for {
+		_, connected, changed := c.publisherSession.state()
+		if connected || !c.isAlive() {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.ctx.Done():
+			return
+		case <-changed:
+		}
+	}
+}
+
+// publishWithMiddlewares publishes serialized event through publish middlewares and traces it
+func (c *Connection) publishWithMiddlewares(ctx context.Context, exchange, routingKey string, b []byte, publishOptions PublishingOptions) (<-chan Confirmation, error) {
+	var confirm <-chan Confirmation
+	publish := c.publishChain(exchange, func(ctx context.Context, exchange, routingKey string, body []byte, publishOptions PublishingOptions) error {
+		end := c.tracer.StartPublish(ctx, exchange, routingKey, &publishOptions)
+		var err error
+		confirm, err = c.publishEvent(exchange, routingKey, body, publishOptions)
+		end(err)
+		return err
+	})
+	err := publish(ctx, exchange, routingKey, b, publishOptions)
+	return confirm, err
+}
+
+// publishEvent publishes serialized event, in confirm mode the returned channel receive the broker confirmation
+func (c *Connection) publishEvent(exchange, routingKey string, body []byte, publishOptions PublishingOptions) (<-chan Confirmation, error) {
+	if !c.IsPublisherConnected() {
+		return nil, CONNECTION_CLOSED_ERROR
+	}
+	if c.IsBlocked() {
+		return nil, BROKER_BLOCKED_ERROR
 	}
+	start := time.Now()
+	confirm, err := c.currentPublishers().get().publish(exchange, routingKey, body, publishOptions)
+	c.metrics.Published(c.ServiceCallerName, exchange, time.Since(start), err)
+	return confirm, err
+}
+
+func publish(channel *amqp.Channel, exchange, routingKey string, body []byte, publishingOptions PublishingOptions) error {
 	p := amqp.Publishing{
 		Headers:         amqp.Table(publishingOptions.Headers),
 		ContentType:     publishingOptions.ContentType,
@@ -48,11 +101,12 @@ func publish(conn *Connection, exchange, routingKey string, body []byte, publish
 		Expiration:      publishingOptions.Expiration,
 		MessageId:       publishingOptions.MessageId,
 		Timestamp:       publishingOptions.Timestamp,
+		Type:            publishingOptions.Type,
 		UserId:          publishingOptions.UserId,
 		AppId:           publishingOptions.AppId,
 		Body:            body,
 	}
-	if err := conn.channel.Publish(exchange, routingKey, false, false, p); err != nil {
+	if err := channel.Publish(exchange, routingKey, publishingOptions.Mandatory, false, p); err != nil {
 		return fmt.Errorf("error in Publishing: %s", err)
 	}
 	return nil
@@ -1,8 +1,9 @@
 package rabbitmq
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"time"
 
@@ -20,184 +21,485 @@ const (
 	delayReconnectTime = 5 * time.Second
 )
 
-// NewConnection create a rabbitmq connection object
+// NewConnection create a rabbitmq connection object, it blocks until connected to rabbitMQ.
+// Reconnecting stops when a signal received from done.
+//
+// Deprecated: use NewConnectionContext and Close the connection instead of done.
 func NewConnection(serviceName string, options *Options, done chan os.Signal) (*Connection, error) {
+	connObj, err := NewConnectionContext(context.Background(), serviceName, options)
+	if err != nil {
+		return nil, err
+	}
+	if done != nil {
+		go func() {
+			select {
+			case <-done:
+				connObj.cancel()
+			case <-connObj.ctx.Done():
+			}
+		}()
+	}
+	return connObj, nil
+}
+
+// NewConnectionContext create a rabbitmq connection object, it blocks until connected to rabbitMQ and returns
+// CONNECTION_TIMEOUT_ERROR with the last dial error when ctx done before connecting. Connecting is retried like
+// reconnecting with Options.Backoff and ReconnectHandler, and RECONNECT_FAILED_ERROR is returned when it exceeds
+// ReconnectMaxAttempts or ReconnectMaxElapsed. Invalid credentials and virtual host are returned immediately.
+// With Options.Lazy it returns immediately and connects in background.
+// ctx only bounds connecting, the connection is open until Close or Shutdown.
+func NewConnectionContext(ctx context.Context, serviceName string, options *Options) (*Connection, error) {
 	opts, err := validateOptions(serviceName, options)
 	if err != nil {
 		return nil, err
 	}
+	tlsConfig, err := newTLSConfig(opts.TLS)
+	if err != nil {
+		return nil, err
+	}
 	connObj := &Connection{
 		ServiceCallerName: serviceName,
 		ConnOpt:           opts,
-		done:              done,
 		alive:             true,
 		queues:            make(map[string]EventHandler),
+		consumers:         make(map[string]*consumer),
+		consumerQueues:    make(map[string]*consumerQueue),
+		nodes:             newNodeSelector(opts.UriAddresses, opts.NodeSelection),
+		tlsConfig:         tlsConfig,
+		logger:            serviceLogger{logger: opts.Logger, service: serviceName},
+		metrics:           opts.Metrics,
+		tracer:            opts.Tracer,
+	}
+	if connObj.tracer == nil {
+		connObj.tracer = nopTracer{}
+	}
+	if connObj.metrics == nil {
+		connObj.metrics = nopMetrics{}
+	}
+	connObj.Observe(connObj.observeConnectionState)
+	for _, observer := range opts.Observers {
+		connObj.Observe(observer)
+	}
+	connObj.ctx, connObj.cancel = context.WithCancel(context.Background())
+	if opts.SeparateConnections {
+		connObj.publisherSession = newSession("publisher", connObj.preparePublisher)
+		connObj.consumerSession = newSession("consumer", connObj.prepareConsumer)
+	} else {
+		s := newSession("publisher and consumer", func(conn *amqp.Connection) error {
+			if err := connObj.prepareConsumer(conn); err != nil {
+				return err
+			}
+			return connObj.preparePublisher(conn)
+		})
+		connObj.publisherSession, connObj.consumerSession = s, s
 	}
-	go connObj.handleReconnect(opts.UriAddress)
-	for {
-		if connObj.isConnected {
-			break
+	if !opts.Lazy {
+		for _, s := range connObj.sessions() {
+			if err := connObj.dial(ctx, s); err != nil {
+				_ = connObj.Close()
+				return nil, err
+			}
 		}
-		time.Sleep(1 * time.Second)
 	}
-
+	for _, s := range connObj.sessions() {
+		go connObj.handleReconnect(s)
+	}
 	return connObj, nil
 }
 
+// dial try to connect session to rabbitMQ for the first time, invalid credentials and virtual host are not retried
+func (c *Connection) dial(ctx context.Context, s *session) error {
+	return c.connectWithBackoff(ctx, s, func(err error) bool {
+		return errors.Is(err, amqp.ErrCredentials) || errors.Is(err, amqp.ErrVhost) || errors.Is(err, amqp.ErrSASL)
+	})
+}
+
+// connectWithBackoff try to connect session to rabbitMQ until connected, waiting between attempts by Options.Backoff.
+// It returns errors accepted by permanent immediately, RECONNECT_FAILED_ERROR when attempts exceed limits of options
+// and CONNECTION_TIMEOUT_ERROR when ctx done, both with the last error of connecting
+func (c *Connection) connectWithBackoff(ctx context.Context, s *session, permanent func(err error) bool) error {
+	now := time.Now()
+	c.logger.Info("attempting to connect to rabbitMQ", "session", s.name, "nodes", redactURIs(c.ConnOpt.UriAddresses))
+	for attempt := 1; ; attempt++ {
+		err := c.connect(s)
+		if err == nil {
+			c.logger.Info("connected to rabbitMQ", "session", s.name, "node", redactURI(s.node()), "elapsed", time.Since(now))
+			return nil
+		}
+		if permanent != nil && permanent(err) {
+			return err
+		}
+		delay := c.ConnOpt.Backoff.Next(attempt)
+		limitReached := c.reconnectLimitReached(attempt, time.Since(now)+delay)
+		if limitReached {
+			// there is no next attempt
+			delay = 0
+		}
+		if c.ConnOpt.ReconnectHandler != nil {
+			c.ConnOpt.ReconnectHandler(attempt, delay, err)
+		}
+		c.emit(ConnectionEvent{Kind: RECONNECT_ATTEMPT_EVENT, Session: s.name, Attempt: attempt, Delay: delay, Err: err})
+		if limitReached {
+			c.logger.Error("cannot connect to rabbitMQ, stop reconnecting", "session", s.name, "attempt", attempt, "error", err)
+			return fmt.Errorf("%w: %v", RECONNECT_FAILED_ERROR, err)
+		}
+		c.logger.Warn("cannot connect to rabbitMQ, try connecting again", "session", s.name, "attempt", attempt, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", CONNECTION_TIMEOUT_ERROR, err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// sessions return distinct sessions of the connection
+func (c *Connection) sessions() []*session {
+	if c.publisherSession == c.consumerSession {
+		return []*session{c.publisherSession}
+	}
+	return []*session{c.publisherSession, c.consumerSession}
+}
+
 // NewEncodedConn will wrap an existing Connection and utilize the appropriate registered encoder
 func NewEncodedConn(c *Connection, encType string) (*EncodedConn, error) {
 	if c == nil {
 		return nil, NIL_CCONECTION_ERROR
 	}
-	ec := &EncodedConn{Conn: c, Enc: EncoderForType(encType)}
+	ec := &EncodedConn{Conn: c, Enc: EncoderForType(encType), ContentType: ContentTypeForEncoder(encType)}
 	if ec.Enc == nil {
 		return nil, fmt.Errorf("no encoder registered for '%s'", encType)
 	}
 	return ec, nil
 }
 
-// connect dial to rabbitMQ server and declare exchange
-func (c *Connection) connect() bool {
-	conn, err := amqp.Dial(c.ConnOpt.UriAddress)
+// connect dial session to rabbitMQ server and prepare the new connection
+func (c *Connection) connect(s *session) error {
+	addr, conn, err := c.dialNode()
 	if err != nil {
-		return false
+		return err
+	}
+	blockings := conn.NotifyBlocked(make(chan amqp.Blocking, 1))
+	if err := c.prepareConnection(s, addr, conn); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	go c.listenBlocked(s, conn, blockings)
+	c.emit(ConnectionEvent{Kind: CONNECTED_EVENT, Session: s.name, Node: addr})
+	if s == c.consumerSession {
+		c.emit(ConnectionEvent{Kind: TOPOLOGY_RESTORED_EVENT, Session: s.name, Node: addr})
+	}
+	return nil
+}
+
+// prepareConnection prepare the new connection of session and mark session connected
+func (c *Connection) prepareConnection(s *session, addr string, conn *amqp.Connection) error {
+	// declarations wait for preparing the connection, so they are declared either by redeclaring topology or after it
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := s.onConnect(conn); err != nil {
+		return err
+	}
+	s.setConnection(addr, conn)
+	return nil
+}
+
+// dialNode dial rabbitMQ nodes in order of node selection until connected to one of them
+func (c *Connection) dialNode() (string, *amqp.Connection, error) {
+	var err error
+	for _, addr := range c.nodes.order() {
+		var conn *amqp.Connection
+		if conn, err = c.dialAddress(addr); err == nil {
+			return addr, conn, nil
+		}
+		if errors.Is(err, amqp.ErrCredentials) || errors.Is(err, amqp.ErrVhost) || errors.Is(err, amqp.ErrSASL) {
+			return "", nil, err
+		}
+		if len(c.ConnOpt.UriAddresses) > 1 {
+			c.logger.Warn("cannot connect to rabbitMQ node", "node", redactURI(addr), "error", err)
+		}
+	}
+	return "", nil, err
+}
+
+// dialAddress dial rabbitMQ node, over TLS if TLS options are set
+func (c *Connection) dialAddress(addr string) (*amqp.Connection, error) {
+	if c.tlsConfig == nil {
+		return amqp.Dial(addr)
 	}
+	// amqp sets server name of the config to the host of addr, so every node needs its own copy
+	return amqp.DialTLS(addr, c.tlsConfig.Clone())
+}
+
+// prepareConsumer redeclare exchanges and queues on the new consuming connection, c.mu is held
+func (c *Connection) prepareConsumer(conn *amqp.Connection) error {
 	ch, err := conn.Channel()
 	if err != nil {
-		return false
-	}
-	c.updateConnection(conn, ch)
-	c.isConnected = true
-	return true
-}
-
-// updateConnection update connection and channel in memory
-func (c *Connection) updateConnection(connection *amqp.Connection, channel *amqp.Channel) {
-	c.conn = connection
-	c.channel = channel
-	c.notifyClose = make(chan *amqp.Error)
-	c.channel.NotifyClose(c.notifyClose)
-}
-
-// handleReconnect if closing rabbitMQ try to connect rabbitMQ continuously
-func (c *Connection) handleReconnect(addr string) {
-	for c.alive {
-		c.isConnected = false
-		now := time.Now()
-		log.Printf("attempting to connect to rabbitMQ %v", addr)
-		retryCount := 0
-		for !c.connect() {
-			if !c.alive {
-				return
-			}
-			select {
-			case <-c.done:
-				return
-			case <-time.After(delayReconnectTime + time.Duration(retryCount)*time.Second):
-				log.Printf("cannot connect to rabbitMQ try connecting to rabbitMQ (next try after %v)...", delayReconnectTime+time.Duration(retryCount)*time.Second)
-				if retryCount != 10 {
-					retryCount++
+		return err
+	}
+	defer ch.Close()
+	if err := c.redeclareTopology(ch); err != nil {
+		c.logger.Error("cannot redeclare exchanges and queues on rabbitMQ", "error", err)
+		return err
+	}
+	return nil
+}
+
+// preparePublisher open publisher pool on the new publishing connection, c.mu is held
+func (c *Connection) preparePublisher(conn *amqp.Connection) error {
+	publishers, err := newPublisherPool(conn, c.ConnOpt.PublisherPoolSize, c.ConnOpt.ConfirmMode, c.ServiceCallerName, c.metrics)
+	if err != nil {
+		return err
+	}
+	c.publishers = publishers
+	return nil
+}
+
+// connectionState return consuming connection state and a channel closed on the next state change
+func (c *Connection) connectionState() (connected bool, alive bool, changed <-chan struct{}) {
+	_, connected, changed = c.consumerSession.state()
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return connected, c.alive, changed
+}
+
+// isAlive check connection is not closed by Close
+func (c *Connection) isAlive() bool {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.alive
+}
+
+// handleReconnect if closing rabbitMQ try to connect session to rabbitMQ continuously
+func (c *Connection) handleReconnect(s *session) {
+	for c.isAlive() {
+		if !s.isConnected() {
+			if err := c.connectWithBackoff(c.ctx, s, nil); err != nil {
+				if errors.Is(err, RECONNECT_FAILED_ERROR) {
+					c.fail(err)
 				}
+				return
 			}
 		}
-		log.Printf("connected to rabbitMQ after %v second", time.Since(now).Seconds())
 		select {
-		case <-c.done:
+		case <-c.ctx.Done():
 			return
-		case <-c.notifyClose:
+		case reason := <-s.closeNotifier():
+			s.setDisconnected()
+			c.emit(ConnectionEvent{Kind: DISCONNECTED_EVENT, Session: s.name, Reason: reason})
 		}
 	}
 }
 
 // ExchangeDeclare declare new exchange with specific kind (direct, topic, fanout, headers)
 func (c *Connection) ExchangeDeclare(exchange string, kind Kind) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if checkElementInSlice(c.exchanges, exchange) {
 		return EXCHANGE_ALREADY_EXISTS_ERROR
 	}
-	c.exchanges = append(c.exchanges, exchange)
-	if err := c.channel.ExchangeDeclare(
-		exchange,
-		kind.String(),
-		c.ConnOpt.DurableExchange,
-		c.ConnOpt.AutoDelete,
-		false,
-		c.ConnOpt.NoWait,
-		nil); err != nil {
+	declaration := exchangeDeclaration{
+		name:       exchange,
+		kind:       kind,
+		durable:    c.ConnOpt.DurableExchange,
+		autoDelete: c.ConnOpt.AutoDelete,
+		internal:   false,
+		noWait:     c.ConnOpt.NoWait,
+	}
+	if err := c.declare(declaration.declare); err != nil {
 		return err
 	}
+	c.exchanges = append(c.exchanges, exchange)
+	c.exchangeDeclarations = append(c.exchangeDeclarations, declaration)
 	return nil
 }
 
 // DeclarePublisherQueue declare new queue and bind queue and bind exchange with routing key
 func (c *Connection) DeclarePublisherQueue(queue, exchange string, routingKey ...string) error {
-	return c.queueDeclare(nil, queue, exchange, routingKey...)
+	return c.queueDeclare(nil, nil, queue, exchange, nil, routingKey...)
 }
 
 // DeclareConsumerQueue declare new queue and bind queue and bind exchange with routing key
 func (c *Connection) DeclareConsumerQueue(eventHandler EventHandler, queue, exchange string, routingKey ...string) error {
-	return c.queueDeclare(eventHandler, queue, exchange, routingKey...)
+	return c.queueDeclare(eventHandler, nil, queue, exchange, nil, routingKey...)
+}
+
+// DeclareConsumerQueueWithOptions declare new queue and bind queue and bind exchange with routing key, opts are applied to the consumer of the queue.
+// rabbitMQ acknowledges events on delivery with Options.AutoAck, so Retry and FailurePolicy of opts which are applied to panicked
+// handlers cannot be honoured and AUTO_ACK_FAILURE_POLICY_ERROR is returned, DeclareAckConsumerQueue applies them regardless of AutoAck
+func (c *Connection) DeclareConsumerQueueWithOptions(eventHandler EventHandler, opts *ConsumerOptions, queue, exchange string, routingKey ...string) error {
+	consumerOpts := validateConsumerOptions(opts)
+	if c.ConnOpt.AutoAck && (consumerOpts.Retry != nil || consumerOpts.FailurePolicy != REQUEUE_ON_FAILURE) {
+		return AUTO_ACK_FAILURE_POLICY_ERROR
+	}
+	return c.consumerQueueDeclare(eventHandler, &consumerQueue{opts: consumerOpts}, queue, exchange, routingKey...)
 }
 
-func (c *Connection) queueDeclare(consumerEventHandler EventHandler, queue, exchange string, routingKey ...string) error {
+// queueDeclare record and declare queue, cq is the consumer options of the queue which is nil for queues without options
+func (c *Connection) queueDeclare(consumerEventHandler EventHandler, cq *consumerQueue, queue, exchange string, args amqp.Table, routingKey ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if _, ok := c.queues[queue]; ok {
 		return QUEUE_ALREADY_EXISTS_ERROR
-	} else {
-		c.queues[queue] = consumerEventHandler
-	}
-	if _, err := c.channel.QueueDeclare(
-		queue,
-		c.ConnOpt.DurableExchange,
-		c.ConnOpt.AutoDelete,
-		c.ConnOpt.ExclusiveQueue,
-		c.ConnOpt.NoWait,
-		nil,
-	); err != nil {
-		return err
 	}
-
+	declaration := queueDeclaration{
+		name:       queue,
+		durable:    c.ConnOpt.DurableExchange,
+		autoDelete: c.ConnOpt.AutoDelete,
+		exclusive:  c.ConnOpt.ExclusiveQueue,
+		noWait:     c.ConnOpt.NoWait,
+		args:       args,
+	}
 	for _, key := range routingKey {
-		if err := c.channel.QueueBind(
-			queue,
-			key,
-			exchange,
-			c.ConnOpt.NoWait,
-			nil,
-		); err != nil {
-			return err
-		}
+		declaration.bindings = append(declaration.bindings, queueBinding{
+			exchange:   exchange,
+			routingKey: key,
+			noWait:     c.ConnOpt.NoWait,
+		})
+	}
+	if err := c.declare(declaration.declare); err != nil {
+		return err
+	}
+	c.queues[queue] = consumerEventHandler
+	if cq != nil {
+		c.consumerQueues[queue] = cq
 	}
+	c.queueDeclarations = append(c.queueDeclarations, declaration)
 	return nil
 }
 
-// IsConnected check rabbitMQ client is connected
+// declare run declaration on a new channel of the consuming connection, when disconnected the declaration
+// is declared after connecting. Failed declarations are not recorded. c.mu must be held
+func (c *Connection) declare(declaration func(channel *amqp.Channel) error) error {
+	conn, connected, _ := c.consumerSession.state()
+	if !connected {
+		return nil
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+	return declaration(channel)
+}
+
+// IsConnected check rabbitMQ client is connected, with Options.SeparateConnections both publishing
+// and consuming connections must be connected
 func (c *Connection) IsConnected() bool {
-	return c.isConnected
+	return c.publisherSession.isConnected() && c.consumerSession.isConnected()
+}
+
+// CurrentNode return address of rabbitMQ node which consuming connection is connected to, empty if disconnected
+func (c *Connection) CurrentNode() string {
+	return c.consumerSession.node()
+}
+
+// CurrentPublisherNode return address of rabbitMQ node which publishing connection is connected to, empty if disconnected
+func (c *Connection) CurrentPublisherNode() string {
+	return c.publisherSession.node()
+}
+
+// IsPublisherConnected check publishing connection is connected
+func (c *Connection) IsPublisherConnected() bool {
+	return c.publisherSession.isConnected()
+}
+
+// IsConsumerConnected check consuming connection is connected
+func (c *Connection) IsConsumerConnected() bool {
+	return c.consumerSession.isConnected()
 }
 
 // GetExchangeList return list of exchanges
 func (c *Connection) GetExchangeList() []string {
-	return c.exchanges
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	exchanges := make([]string, len(c.exchanges))
+	copy(exchanges, c.exchanges)
+	return exchanges
 }
 
 // GetQueueList return list of queues with handlers
 func (c *Connection) GetQueueList() map[string]EventHandler {
-	return c.queues
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	queues := make(map[string]EventHandler, len(c.queues))
+	for queue, handler := range c.queues {
+		queues[queue] = handler
+	}
+	return queues
 }
 
-//Close stop rabbitMQ client
-func (c *Connection) Close() error {
-	if !c.isConnected {
-		return nil
+// hasExchange check exchange is declared
+func (c *Connection) hasExchange(exchange string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return checkElementInSlice(c.exchanges, exchange)
+}
+
+// hasQueue check queue is declared
+func (c *Connection) hasQueue(queue string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.queues[queue]
+	return ok
+}
+
+// currentPublishers return the publisher pool which is in use
+func (c *Connection) currentPublishers() *publisherPool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.publishers
+}
+
+// reconnectLimitReached check attempts or elapsed time of reconnecting exceed limits of options
+func (c *Connection) reconnectLimitReached(attempt int, elapsed time.Duration) bool {
+	if c.ConnOpt.ReconnectMaxAttempts > 0 && attempt >= c.ConnOpt.ReconnectMaxAttempts {
+		return true
+	}
+	return c.ConnOpt.ReconnectMaxElapsed > 0 && elapsed > c.ConnOpt.ReconnectMaxElapsed
+}
+
+// fail move connection to failed state and close it
+func (c *Connection) fail(err error) {
+	c.stateMu.Lock()
+	if c.err == nil {
+		c.err = err
 	}
+	c.stateMu.Unlock()
+	_ = c.Close()
+}
+
+// Err return the reason of failed state when reconnecting exceeded limits of options, nil otherwise
+func (c *Connection) Err() error {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.err
+}
+
+// Close stop rabbitMQ client
+func (c *Connection) Close() error {
+	c.stateMu.Lock()
+	wasAlive := c.alive
 	c.alive = false
-	if err := c.channel.Close(); err != nil {
-		return err
+	failErr := c.err
+	c.stateMu.Unlock()
+	if wasAlive {
+		defer c.emit(ConnectionEvent{Kind: CLOSED_EVENT, Err: failErr})
 	}
-	if err := c.conn.Close(); err != nil {
-		return err
+	c.cancel()
+	var closeErr error
+	for _, s := range c.sessions() {
+		conn, connected, _ := s.state()
+		if connected {
+			if err := conn.Close(); err != nil && closeErr == nil {
+				closeErr = err
+			}
+		}
+		s.setDisconnected()
 	}
-	c.isConnected = false
-	log.Printf("gracefully stopped rabbitMQ connection")
+	if closeErr != nil {
+		return closeErr
+	}
+	c.logger.Info("gracefully stopped rabbitMQ connection")
 	return nil
 }
 
@@ -237,10 +539,24 @@ func validateOptions(serviceName string, newOpt *Options) (*Options, error) {
 	if len(serviceName) == 0 {
 		return nil, SERVICE_NAME_ERROR
 	}
-	if len(newOpt.UriAddress) == 0 {
-		return nil, URI_ADDRESS_ERROR
+	if len(newOpt.UriAddresses) == 0 {
+		opt.UriAddresses = []string{newOpt.UriAddress}
 	} else {
-		opt.UriAddress = newOpt.UriAddress
+		opt.UriAddresses = append([]string(nil), newOpt.UriAddresses...)
+	}
+	for _, addr := range opt.UriAddresses {
+		uri, err := amqp.ParseURI(addr)
+		if err != nil {
+			return nil, URI_ADDRESS_ERROR
+		}
+		// amqp dials plain connections for amqp scheme even if TLS config is set
+		if newOpt.TLS != nil && uri.Scheme != "amqps" {
+			return nil, TLS_SCHEME_ERROR
+		}
+	}
+	opt.UriAddress = opt.UriAddresses[0]
+	if newOpt.NodeSelection != opt.NodeSelection {
+		opt.NodeSelection = newOpt.NodeSelection
 	}
 	if newOpt.DurableExchange != opt.DurableExchange {
 		opt.DurableExchange = newOpt.DurableExchange
@@ -257,6 +573,37 @@ func validateOptions(serviceName string, newOpt *Options) (*Options, error) {
 	if newOpt.ExclusiveQueue != opt.ExclusiveQueue {
 		opt.ExclusiveQueue = newOpt.ExclusiveQueue
 	}
+	if newOpt.Lazy != opt.Lazy {
+		opt.Lazy = newOpt.Lazy
+	}
+	if newOpt.PublisherPoolSize > 0 {
+		opt.PublisherPoolSize = newOpt.PublisherPoolSize
+	}
+	if newOpt.SeparateConnections != opt.SeparateConnections {
+		opt.SeparateConnections = newOpt.SeparateConnections
+	}
+	if newOpt.ConfirmMode != opt.ConfirmMode {
+		opt.ConfirmMode = newOpt.ConfirmMode
+	}
+	if newOpt.Backoff != nil {
+		opt.Backoff = newOpt.Backoff
+	}
+	if newOpt.ReconnectMaxAttempts > 0 {
+		opt.ReconnectMaxAttempts = newOpt.ReconnectMaxAttempts
+	}
+	if newOpt.ReconnectMaxElapsed > 0 {
+		opt.ReconnectMaxElapsed = newOpt.ReconnectMaxElapsed
+	}
+	opt.ReconnectHandler = newOpt.ReconnectHandler
+	opt.Metrics = newOpt.Metrics
+	opt.Tracer = newOpt.Tracer
+	if newOpt.Logger != nil {
+		opt.Logger = newOpt.Logger
+	}
+	opt.TLS = newOpt.TLS
+	opt.BlockedHandler = newOpt.BlockedHandler
+	opt.ErrorHandler = newOpt.ErrorHandler
+	opt.Observers = append([]ConnectionObserver(nil), newOpt.Observers...)
 	return opt, nil
 }
 
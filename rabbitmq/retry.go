@@ -0,0 +1,156 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	RETRY_ATTEMPT_HEADER        = "x-retry-attempt"        // RETRY_ATTEMPT_HEADER header of number of retries of the event
+	ORIGINAL_EXCHANGE_HEADER    = "x-original-exchange"    // ORIGINAL_EXCHANGE_HEADER header of the exchange event first published to
+	ORIGINAL_ROUTING_KEY_HEADER = "x-original-routing-key" // ORIGINAL_ROUTING_KEY_HEADER header of the routing key event first published with
+)
+
+const (
+	defaultRetryMaxAttempts  = 3
+	defaultRetryInitialDelay = 1 * time.Second
+	defaultRetryMultiplier   = 2
+	defaultRetryMaxDelay     = 1 * time.Hour
+	retryPublishTimeout      = 30 * time.Second // retryPublishTimeout of waiting for unblocking and confirmation of publishing to retry queue
+)
+
+// RetryOptions options for retrying failed events of a queue with exponential backoff.
+// For each attempt a retry queue named <queue>.retry.<attempt> is declared with x-message-ttl of the
+// attempt delay, expired events are dead-lettered back to the queue. Events failed after MaxAttempts
+// retries are moved to the parking queue named <queue>.parking.
+type RetryOptions struct {
+	MaxAttempts  int           // MaxAttempts number of retries before parking the event, default is 3
+	InitialDelay time.Duration // InitialDelay delay of the first retry, default is 1 second
+	Multiplier   float64       // Multiplier of the delay for each next retry, default is 2
+	MaxDelay     time.Duration // MaxDelay maximum delay of retries, default is 1 hour
+}
+
+// RetryQueueName return name of the retry queue of queue for attempt
+func RetryQueueName(queue string, attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", queue, attempt)
+}
+
+// ParkingQueueName return name of the queue which events of queue are moved to after max retry attempts
+func ParkingQueueName(queue string) string {
+	return fmt.Sprintf("%s.parking", queue)
+}
+
+// delay return delay of the attempt
+func (r *RetryOptions) delay(attempt int) time.Duration {
+	d := float64(r.InitialDelay) * math.Pow(r.Multiplier, float64(attempt-1))
+	if d > float64(r.MaxDelay) {
+		return r.MaxDelay
+	}
+	return time.Duration(d)
+}
+
+// declareRetryQueues declare retry queues and parking queue of queue
+func (c *Connection) declareRetryQueues(queue string, opts *RetryOptions) error {
+	for attempt := 1; attempt <= opts.MaxAttempts; attempt++ {
+		if err := c.queueDeclare(nil, nil, RetryQueueName(queue, attempt), "", amqp.Table{
+			"x-message-ttl":             opts.delay(attempt).Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		}); err != nil {
+			return err
+		}
+	}
+	return c.queueDeclare(nil, nil, ParkingQueueName(queue), "", nil)
+}
+
+// retryEvent publishes the event to the retry queue of the next attempt or to the parking queue and acknowledge it,
+// it waits for unblocking and confirmation of publishing at most retryPublishTimeout
+func (c *Connection) retryEvent(opts *RetryOptions, queue string, delivery Delivery, park bool) error {
+	attempt := retryAttempt(delivery) + 1
+	target := RetryQueueName(queue, attempt)
+	if park || attempt > opts.MaxAttempts {
+		target = ParkingQueueName(queue)
+	}
+	publishOptions := publishingOptionsFromDelivery(delivery)
+	publishOptions.Headers[RETRY_ATTEMPT_HEADER] = int32(attempt)
+	if _, ok := publishOptions.Headers[ORIGINAL_EXCHANGE_HEADER]; !ok {
+		publishOptions.Headers[ORIGINAL_EXCHANGE_HEADER] = delivery.Exchange
+		publishOptions.Headers[ORIGINAL_ROUTING_KEY_HEADER] = delivery.RoutingKey
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, retryPublishTimeout)
+	defer cancel()
+	if err := c.waitUnblocked(ctx); err != nil {
+		return err
+	}
+	confirm, err := c.publishEvent("", target, delivery.Body, publishOptions)
+	if err != nil {
+		return err
+	}
+	if confirm != nil {
+		if err := waitConfirmation(ctx, confirm); err != nil {
+			return err
+		}
+	}
+	return c.settle(queue, ACKED_DELIVERY, delivery.Ack(false))
+}
+
+// retryAttempt return number of retries of the event from its headers
+func retryAttempt(delivery Delivery) int {
+	switch v := delivery.Headers[RETRY_ATTEMPT_HEADER].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int16:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+// publishingOptionsFromDelivery copy properties and headers of delivery for publishing it again,
+// UserId is not copied because rabbitMQ rejects events whose user id is not the user of the connection
+func publishingOptionsFromDelivery(delivery Delivery) PublishingOptions {
+	headers := make(Headers, len(delivery.Headers)+3)
+	for k, v := range delivery.Headers {
+		headers[k] = v
+	}
+	return PublishingOptions{
+		Headers:         headers,
+		ContentType:     delivery.ContentType,
+		ContentEncoding: delivery.ContentEncoding,
+		DeliveryMode:    delivery.DeliveryMode,
+		Priority:        delivery.Priority,
+		CorrelationId:   delivery.CorrelationId,
+		ReplyTo:         delivery.ReplyTo,
+		Expiration:      delivery.Expiration,
+		MessageId:       delivery.MessageId,
+		Timestamp:       delivery.Timestamp,
+		Type:            delivery.Type,
+		AppId:           delivery.AppId,
+	}
+}
+
+// validateRetryOptions fill default values of retry options
+func validateRetryOptions(opts *RetryOptions) *RetryOptions {
+	retryOpts := *opts
+	if retryOpts.MaxAttempts <= 0 {
+		retryOpts.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if retryOpts.InitialDelay <= 0 {
+		retryOpts.InitialDelay = defaultRetryInitialDelay
+	}
+	if retryOpts.Multiplier < 1 {
+		retryOpts.Multiplier = defaultRetryMultiplier
+	}
+	if retryOpts.MaxDelay <= 0 {
+		retryOpts.MaxDelay = defaultRetryMaxDelay
+	}
+	return &retryOpts
+}
@@ -0,0 +1,71 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryRequeuesLaterWhenRetryQueueUnavailable(t *testing.T) {
+	broker := newFakeBroker(t, nil)
+	conn := newTestConnection(t, broker, &Options{ConfirmMode: true, ErrorHandler: func(string, Delivery, error) {}})
+	if err := conn.ExchangeDeclare("ex", DIRECT); err != nil {
+		t.Fatal(err)
+	}
+	var calls int64
+	handlerErr := errors.New("failed")
+	err := conn.DeclareAckConsumerQueue(func(ctx context.Context, queue string, delivery Delivery) error {
+		atomic.AddInt64(&calls, 1)
+		return handlerErr
+	}, &ConsumerOptions{Retry: &RetryOptions{}, RetryDelay: 300 * time.Millisecond}, "q", "ex", "rk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Consume(); err != nil {
+		t.Fatal(err)
+	}
+	// publishing to retry queues through the default exchange closes the channel
+	broker.deleteExchange("")
+	broker.enqueue("q", &fakeMessage{exchange: "ex", routingKey: "rk", body: []byte("event")})
+
+	eventually(t, 2*time.Second, func() bool { return atomic.LoadInt64(&calls) == 1 }, "event is not handled")
+	time.Sleep(200 * time.Millisecond)
+	if n := atomic.LoadInt64(&calls); n != 1 {
+		t.Fatalf("event is handled %d times before RetryDelay, want 1", n)
+	}
+	eventually(t, 2*time.Second, func() bool { return atomic.LoadInt64(&calls) >= 2 }, "event is not requeued after RetryDelay")
+}
+
+func TestRetryRequiresManualAck(t *testing.T) {
+	broker := newFakeBroker(t, nil)
+	conn := newTestConnection(t, broker, &Options{AutoAck: true, ErrorHandler: func(string, Delivery, error) {}})
+	if err := conn.ExchangeDeclare("ex", DIRECT); err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []*ConsumerOptions{{Retry: &RetryOptions{}}, {FailurePolicy: REJECT_ON_FAILURE}} {
+		err := conn.DeclareConsumerQueueWithOptions(func(string, Delivery) {}, opts, "q", "ex", "rk")
+		if !errors.Is(err, AUTO_ACK_FAILURE_POLICY_ERROR) {
+			t.Fatalf("declaring queue with %+v and AutoAck returned %v, want %v", opts, err, AUTO_ACK_FAILURE_POLICY_ERROR)
+		}
+	}
+	if broker.messageCount("q") != 0 || conn.hasQueue("q") || conn.hasQueue(RetryQueueName("q", 1)) {
+		t.Fatal("queues are declared by failed declaration")
+	}
+
+	// events of ack consumer queues are retried with AutoAck
+	err := conn.DeclareAckConsumerQueue(func(ctx context.Context, queue string, delivery Delivery) error {
+		panic("failed")
+	}, &ConsumerOptions{Retry: &RetryOptions{InitialDelay: time.Hour}}, "q", "ex", "rk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Consume(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Publish("ex", "rk", map[string]string{"a": "b"}, PublishingOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, 2*time.Second, func() bool { return broker.messageCount(RetryQueueName("q", 1)) == 1 }, "panicked event is not retried")
+}
@@ -0,0 +1,106 @@
+package rabbitmq
+
+import (
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// session is an AMQP connection to rabbitMQ with its own reconnect loop
+type session struct {
+	name         string // name of the session in logs, publisher or consumer
+	mu           sync.Mutex
+	conn         *amqp.Connection
+	addr         string // addr of rabbitMQ node which conn is connected to
+	connected    bool
+	blocked      bool // blocked rabbitMQ blocked the connection
+	notifyClose  chan *amqp.Error
+	stateChanged chan struct{} // stateChanged closed and replaced on every connection or blocked state change
+	// onConnect prepare the new connection before the session is marked connected, c.mu is held
+	onConnect func(conn *amqp.Connection) error
+}
+
+// newSession create disconnected session
+func newSession(name string, onConnect func(conn *amqp.Connection) error) *session {
+	return &session{
+		name:         name,
+		stateChanged: make(chan struct{}),
+		onConnect:    onConnect,
+	}
+}
+
+// setConnection update connection of the session and broadcast the state change
+func (s *session) setConnection(addr string, conn *amqp.Connection) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addr = addr
+	s.conn = conn
+	s.notifyClose = conn.NotifyClose(make(chan *amqp.Error, 1))
+	s.connected = true
+	s.blocked = false
+	s.broadcast()
+}
+
+// setDisconnected mark session disconnected and broadcast the state change
+func (s *session) setDisconnected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected = false
+	s.blocked = false
+	s.broadcast()
+}
+
+// setBlocked update blocked state of conn and broadcast the state change, it reports whether state changed
+func (s *session) setBlocked(conn *amqp.Connection, blocked bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != conn || !s.connected || s.blocked == blocked {
+		return false
+	}
+	s.blocked = blocked
+	s.broadcast()
+	return true
+}
+
+// blockedState return blocked state and a channel closed on the next state change
+func (s *session) blockedState() (bool, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blocked, s.stateChanged
+}
+
+// broadcast wake up waiters of state change, s.mu must be held
+func (s *session) broadcast() {
+	close(s.stateChanged)
+	s.stateChanged = make(chan struct{})
+}
+
+// state return connection, connected state and a channel closed on the next state change
+func (s *session) state() (*amqp.Connection, bool, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn, s.connected, s.stateChanged
+}
+
+// node return address of connected rabbitMQ node, empty if disconnected
+func (s *session) node() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.connected {
+		return ""
+	}
+	return s.addr
+}
+
+// isConnected check session is connected
+func (s *session) isConnected() bool {
+	_, connected, _ := s.state()
+	return connected
+}
+
+// closeNotifier return channel which receive closing of the current connection
+func (s *session) closeNotifier() chan *amqp.Error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notifyClose
+}
@@ -0,0 +1,56 @@
+package rabbitmq
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Shutdown gracefully stop rabbitMQ client. It cancels all consumers so no new event is delivered,
+// waits for in-flight handlers, requeues failed events waiting for RetryDelay, waits for pending
+// publisher confirms and then closes the connection.
+// When ctx expires first, the context passed to handlers is cancelled, the connection is closed and
+// *ShutdownError reports the unfinished handlers and confirms.
+func (c *Connection) Shutdown(ctx context.Context) error {
+	c.mu.RLock()
+	consumers := make(map[string]*consumer, len(c.consumers))
+	for queue, cs := range c.consumers {
+		consumers[queue] = cs
+	}
+	c.mu.RUnlock()
+	for _, cs := range consumers {
+		cs.cancel()
+	}
+	handlersDone := make(chan struct{})
+	go func() {
+		c.consumersWg.Wait()
+		close(handlersDone)
+	}()
+	select {
+	case <-handlersDone:
+	case <-ctx.Done():
+		c.cancel()
+	}
+	// events waiting for requeue after RetryDelay are requeued now, their timers would fire after closing
+	for queue, cs := range consumers {
+		for _, delivery := range cs.takeRequeues() {
+			c.requeue(queue, delivery)
+		}
+	}
+	pendingConfirms := 0
+	if publishers := c.currentPublishers(); publishers != nil {
+		pendingConfirms = publishers.wait(ctx)
+	}
+	inFlight := make(map[string]int)
+	for queue, cs := range consumers {
+		if n := atomic.LoadInt64(&cs.inFlight); n > 0 {
+			inFlight[queue] = int(n)
+		}
+	}
+	if err := c.Close(); err != nil {
+		return err
+	}
+	if len(inFlight) != 0 || pendingConfirms != 0 {
+		return &ShutdownError{InFlightHandlers: inFlight, PendingConfirms: pendingConfirms, Err: ctx.Err()}
+	}
+	return nil
+}
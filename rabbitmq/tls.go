@@ -0,0 +1,55 @@
+package rabbitmq
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSOptions for connecting to rabbitMQ over TLS, addresses must use amqps scheme otherwise TLS_SCHEME_ERROR is returned
+type TLSOptions struct {
+	CAFile             string         // CAFile PEM encoded certificates of CAs verifying rabbitMQ certificate
+	RootCAs            *x509.CertPool // RootCAs verifying rabbitMQ certificate, certificates of CAFile are appended to this pool, system pool is used if both are empty
+	CertFile           string         // CertFile PEM encoded client certificate for mutual TLS
+	KeyFile            string         // KeyFile PEM encoded private key of CertFile
+	ServerName         string         // ServerName verified in rabbitMQ certificate, default is host of the node address
+	MinVersion         uint16         // MinVersion of TLS, default is TLS 1.2
+	InsecureSkipVerify bool           // InsecureSkipVerify do not verify rabbitMQ certificate, only use it for development
+}
+
+// newTLSConfig create tls config from options, it is created once and reused on reconnecting
+func newTLSConfig(opts *TLSOptions) (*tls.Config, error) {
+	if opts == nil {
+		return nil, nil
+	}
+	cfg := &tls.Config{
+		RootCAs:            opts.RootCAs,
+		ServerName:         opts.ServerName,
+		MinVersion:         opts.MinVersion,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	if len(opts.CAFile) != 0 {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA file: %w", err)
+		}
+		if cfg.RootCAs == nil {
+			cfg.RootCAs = x509.NewCertPool()
+		}
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, TLS_CA_INVALID_ERROR
+		}
+	}
+	if len(opts.CertFile) != 0 || len(opts.KeyFile) != 0 {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,66 @@
+package rabbitmq
+
+import "github.com/streadway/amqp"
+
+// exchangeDeclaration record of declared exchange, replayed after reconnect
+type exchangeDeclaration struct {
+	name       string
+	kind       Kind
+	durable    bool
+	autoDelete bool
+	internal   bool
+	noWait     bool
+	args       amqp.Table
+}
+
+// queueDeclaration record of declared queue and its bindings, replayed after reconnect
+type queueDeclaration struct {
+	name       string
+	durable    bool
+	autoDelete bool
+	exclusive  bool
+	noWait     bool
+	args       amqp.Table
+	bindings   []queueBinding
+}
+
+// queueBinding record of binding between queue and exchange with routing key
+type queueBinding struct {
+	exchange   string
+	routingKey string
+	noWait     bool
+	args       amqp.Table
+}
+
+// declare declare exchange on channel
+func (e exchangeDeclaration) declare(channel *amqp.Channel) error {
+	return channel.ExchangeDeclare(e.name, e.kind.String(), e.durable, e.autoDelete, e.internal, e.noWait, e.args)
+}
+
+// declare declare queue on channel and bind it to exchanges
+func (q queueDeclaration) declare(channel *amqp.Channel) error {
+	if _, err := channel.QueueDeclare(q.name, q.durable, q.autoDelete, q.exclusive, q.noWait, q.args); err != nil {
+		return err
+	}
+	for _, b := range q.bindings {
+		if err := channel.QueueBind(q.name, b.routingKey, b.exchange, b.noWait, b.args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redeclareTopology declare all recorded exchanges, queues and bindings on channel
+func (c *Connection) redeclareTopology(channel *amqp.Channel) error {
+	for _, e := range c.exchangeDeclarations {
+		if err := e.declare(channel); err != nil {
+			return err
+		}
+	}
+	for _, q := range c.queueDeclarations {
+		if err := q.declare(channel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package rabbitmq
+
+import "context"
+
+// Tracer trace publishing and consuming of events, the tracing package has the OpenTelemetry implementation.
+// StartPublish may set trace headers of publishOptions, the returned functions end the span with the result
+type Tracer interface {
+	StartPublish(ctx context.Context, exchange, routingKey string, publishOptions *PublishingOptions) func(err error)
+	StartConsume(ctx context.Context, queue string, delivery Delivery) (context.Context, func(err error))
+}
+
+// nopTracer does not trace, it is used when Options.Tracer is nil
+type nopTracer struct{}
+
+func (nopTracer) StartPublish(context.Context, string, string, *PublishingOptions) func(error) {
+	return func(error) {}
+}
+
+func (nopTracer) StartConsume(ctx context.Context, _ string, _ Delivery) (context.Context, func(error)) {
+	return ctx, func(error) {}
+}
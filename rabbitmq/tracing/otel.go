@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ramoozorg/event-driven/rabbitmq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/ramoozorg/event-driven/rabbitmq"
+
+// OtelTracer is an OpenTelemetry Tracer implementation for Connection.
+// Trace context is injected into headers of published events and extracted from headers of consumed events,
+// spans have messaging semantic convention attributes.
+type OtelTracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+var _ rabbitmq.Tracer = (*OtelTracer)(nil)
+
+// NewOtelTracer create OtelTracer with provider and propagator, the global tracer provider is used if provider is nil
+// and W3C trace context propagator (traceparent and tracestate headers) is used if propagator is nil
+func NewOtelTracer(provider trace.TracerProvider, propagator propagation.TextMapPropagator) *OtelTracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+	return &OtelTracer{
+		tracer:     provider.Tracer(instrumentationName),
+		propagator: propagator,
+	}
+}
+
+// StartPublish start producer span and inject its context into a copy of headers of publishOptions
+func (t *OtelTracer) StartPublish(ctx context.Context, exchange, routingKey string, publishOptions *rabbitmq.PublishingOptions) func(err error) {
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystem("rabbitmq"),
+		semconv.MessagingOperationPublish,
+		semconv.MessagingDestinationName(exchange),
+		semconv.MessagingRabbitmqDestinationRoutingKey(routingKey),
+	}
+	if exchange == "" {
+		attrs = append(attrs, semconv.MessagingDestinationAnonymous(true))
+	}
+	if len(publishOptions.MessageId) != 0 {
+		attrs = append(attrs, semconv.MessagingMessageID(publishOptions.MessageId))
+	}
+	if len(publishOptions.CorrelationId) != 0 {
+		attrs = append(attrs, semconv.MessagingMessageConversationID(publishOptions.CorrelationId))
+	}
+	ctx, span := t.tracer.Start(ctx, fmt.Sprintf("%s publish", exchange),
+		trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(attrs...))
+	headers := make(rabbitmq.Headers, len(publishOptions.Headers)+2)
+	for k, v := range publishOptions.Headers {
+		headers[k] = v
+	}
+	t.propagator.Inject(ctx, headerCarrier(headers))
+	publishOptions.Headers = headers
+	return func(err error) {
+		endSpan(span, err)
+	}
+}
+
+// StartConsume start consumer span which is child of the trace context extracted from headers of delivery
+func (t *OtelTracer) StartConsume(ctx context.Context, queue string, delivery rabbitmq.Delivery) (context.Context, func(err error)) {
+	ctx = t.Extract(ctx, delivery)
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystem("rabbitmq"),
+		semconv.MessagingOperationProcess,
+		semconv.MessagingSourceName(queue),
+		semconv.MessagingRabbitmqDestinationRoutingKey(delivery.RoutingKey),
+		semconv.MessagingMessagePayloadSizeBytes(len(delivery.Body)),
+	}
+	if len(delivery.MessageId) != 0 {
+		attrs = append(attrs, semconv.MessagingMessageID(delivery.MessageId))
+	}
+	if len(delivery.CorrelationId) != 0 {
+		attrs = append(attrs, semconv.MessagingMessageConversationID(delivery.CorrelationId))
+	}
+	ctx, span := t.tracer.Start(ctx, fmt.Sprintf("%s process", queue),
+		trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		endSpan(span, err)
+	}
+}
+
+// Extract return ctx with trace context of delivery headers, it is useful for handlers without context
+func (t *OtelTracer) Extract(ctx context.Context, delivery rabbitmq.Delivery) context.Context {
+	return t.propagator.Extract(ctx, headerCarrier(delivery.Headers))
+}
+
+// endSpan record err on span and end it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// headerCarrier adapt event headers to propagation.TextMapCarrier
+type headerCarrier map[string]interface{}
+
+func (h headerCarrier) Get(key string) string {
+	if v, ok := h[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func (h headerCarrier) Set(key, value string) {
+	h[key] = value
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ramoozorg/event-driven/rabbitmq"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestTracer() (*OtelTracer, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	return NewOtelTracer(provider, nil), exporter
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, attr := range attrs {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConsumeSpanIsChildOfPublishSpan(t *testing.T) {
+	tracer, exporter := newTestTracer()
+	publishOptions := rabbitmq.PublishingOptions{Headers: rabbitmq.Headers{"a": "b"}, MessageId: "id"}
+	original := publishOptions.Headers
+	endPublish := tracer.StartPublish(context.Background(), "ex", "rk", &publishOptions)
+	endPublish(nil)
+
+	if _, ok := publishOptions.Headers["traceparent"].(string); !ok {
+		t.Fatalf("traceparent is not injected into headers %v", publishOptions.Headers)
+	}
+	if publishOptions.Headers["a"] != "b" {
+		t.Fatal("headers of event are not kept")
+	}
+	if _, ok := original["traceparent"]; ok {
+		t.Fatal("headers of caller are modified")
+	}
+
+	delivery := rabbitmq.Delivery{
+		Headers:    amqp.Table(publishOptions.Headers),
+		RoutingKey: "rk",
+		MessageId:  "id",
+		Body:       []byte("body"),
+	}
+	ctx, endConsume := tracer.StartConsume(context.Background(), "q", delivery)
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		t.Fatal("context of handler has no span")
+	}
+	endConsume(nil)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans are exported, want 2", len(spans))
+	}
+	publish, consume := spans[0], spans[1]
+	if publish.SpanKind != trace.SpanKindProducer || consume.SpanKind != trace.SpanKindConsumer {
+		t.Fatalf("span kinds are %v and %v, want producer and consumer", publish.SpanKind, consume.SpanKind)
+	}
+	if consume.Parent.SpanID() != publish.SpanContext.SpanID() || consume.SpanContext.TraceID() != publish.SpanContext.TraceID() {
+		t.Fatal("consume span is not child of publish span")
+	}
+	if !consume.Parent.IsRemote() {
+		t.Fatal("parent of consume span is not extracted from headers")
+	}
+	for _, attr := range []attribute.KeyValue{
+		semconv.MessagingSystem("rabbitmq"),
+		semconv.MessagingOperationPublish,
+		semconv.MessagingDestinationName("ex"),
+		semconv.MessagingRabbitmqDestinationRoutingKey("rk"),
+		semconv.MessagingMessageID("id"),
+	} {
+		if !hasAttribute(publish.Attributes, attr) {
+			t.Errorf("publish span has no attribute %v=%v", attr.Key, attr.Value.Emit())
+		}
+	}
+	for _, attr := range []attribute.KeyValue{
+		semconv.MessagingSystem("rabbitmq"),
+		semconv.MessagingOperationProcess,
+		semconv.MessagingSourceName("q"),
+		semconv.MessagingRabbitmqDestinationRoutingKey("rk"),
+		semconv.MessagingMessageID("id"),
+		semconv.MessagingMessagePayloadSizeBytes(4),
+	} {
+		if !hasAttribute(consume.Attributes, attr) {
+			t.Errorf("consume span has no attribute %v=%v", attr.Key, attr.Value.Emit())
+		}
+	}
+}
+
+func TestSpanRecordsError(t *testing.T) {
+	tracer, exporter := newTestTracer()
+	_, endConsume := tracer.StartConsume(context.Background(), "q", rabbitmq.Delivery{})
+	endConsume(errors.New("failed"))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("%d spans are exported, want 1", len(spans))
+	}
+	if spans[0].Status.Code != codes.Error || spans[0].Status.Description != "failed" {
+		t.Fatalf("span status is %+v, want error", spans[0].Status)
+	}
+	if spans[0].Parent.IsValid() {
+		t.Fatal("span of event without trace headers has parent")
+	}
+}